@@ -0,0 +1,36 @@
+package bst
+
+// aggregate sets n's aggregate from its value and its children's aggregates.
+func (t *treeInfo) aggregate(n *BasicBST) {
+	if t == nil || t.combine == nil {
+		return
+	}
+	a := t.combine(t.aggregateOf(n.Child[lo]), t.extract(n.Value))
+	n.agg = t.combine(a, t.aggregateOf(n.Child[hi]))
+}
+
+func (t *treeInfo) aggregateOf(n *BasicBST) interface{} {
+	if n == nil {
+		return t.zero
+	}
+	return n.agg
+}
+
+// rangeAggregate returns the aggregate of the values of n's subtree whose keys
+// lie between from and to inclusive, where a nil bound is open. Below the
+// node splitting the range, each side follows a single path and takes the
+// stored aggregates of the whole subtrees hanging off it.
+func (t *treeInfo) rangeAggregate(n *BasicBST, from, to KeyType) interface{} {
+	switch {
+	case n == nil:
+		return t.zero
+	case from == nil && to == nil:
+		return n.agg
+	case from != nil && n.Key.Less(from):
+		return t.rangeAggregate(n.Child[hi], from, to)
+	case to != nil && to.Less(n.Key):
+		return t.rangeAggregate(n.Child[lo], from, to)
+	}
+	a := t.combine(t.rangeAggregate(n.Child[lo], from, nil), t.extract(n.Value))
+	return t.combine(a, t.rangeAggregate(n.Child[hi], nil, to))
+}
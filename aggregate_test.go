@@ -0,0 +1,49 @@
+package bst
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSubtreeAggregate(t *testing.T) {
+	s := NewBasicAggregate(0,
+		func(v interface{}) interface{} { return v },
+		func(a, b interface{}) interface{} { return a.(int) + b.(int) })
+	for _, k := range rand.Perm(100) {
+		s.Insert(iKey(k), k)
+	}
+	brute := func(from, to int) int {
+		sum := 0
+		s.RangeVisit(iKey(from), iKey(to), func(n *BasicBST) error {
+			sum += n.Value.(int)
+			return nil
+		})
+		return sum
+	}
+	check := func(stage string) {
+		for _, r := range [][2]int{{20, 40}, {0, 99}, {-5, 3}, {98, 200}, {50, 50}, {40, 20}} {
+			got := s.SubtreeAggregate(iKey(r[0]), iKey(r[1])).(int)
+			if want := brute(r[0], r[1]); got != want {
+				t.Errorf("%s: bad aggregate over [%d, %d]: got %d, want %d", stage, r[0], r[1], got, want)
+			}
+		}
+	}
+	check("insert")
+	if got := s.SubtreeAggregate(iKey(20), iKey(40)).(int); got != 630 {
+		t.Errorf("bad aggregate over [20, 40]: got %d, want 630", got)
+	}
+	for _, k := range [...]int{30, 0, 64, 99} {
+		s.Get(iKey(k)).Delete()
+	}
+	check("delete")
+	s.Get(iKey(25)).RotateLeft()
+	s.Child[lo].RotateRight()
+	s.Insert(iKey(35), 1000)
+	s.Update(iKey(36), func(old interface{}) interface{} { return old.(int) * 2 })
+	check("rotate and update")
+	s.MapValues(func(k KeyType, v interface{}) interface{} { return -v.(int) })
+	check("map")
+	if NewBasic().SubtreeAggregate(iKey(0), iKey(1)) != nil {
+		t.Errorf("non-aggregating tree returned an aggregate")
+	}
+}
@@ -0,0 +1,52 @@
+package bst
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// AtomicBST is a BasicBST for concurrent readers that never wait. Each write
+// builds a new immutable tree, sharing all but the changed path with the old
+// one as PersistentInsert does, and swaps it in atomically, so every reader
+// works on a consistent snapshot without taking a lock.
+//
+// A write copies the path from the root down to its key, so it costs time and
+// memory in proportion to the depth of that key. The snapshots are never
+// rebalanced, so keys written in sorted order build a list and each write
+// then costs O(n); mix the key order, or have an occasional Update return a
+// balanced copy such as old.Filter of every pair, which costs O(n) once.
+// Every write bumps the version shared by all the snapshots, so a reader can
+// find what changed since an earlier snapshot with ChangedSince.
+type AtomicBST struct {
+	mu   sync.Mutex // serializes writers
+	root atomic.Pointer[BasicBST]
+}
+
+// NewAtomic allocates a new AtomicBST.
+func NewAtomic() *AtomicBST {
+	t := new(AtomicBST)
+	t.root.Store(NewBasic())
+	return t
+}
+
+// Load returns the current snapshot. It must be treated as immutable: read
+// it with Get, Lookup, Visit and the like, but never modify it.
+func (t *AtomicBST) Load() *BasicBST {
+	return t.root.Load()
+}
+
+// Update replaces the current snapshot with the tree fn returns, which fn
+// must build without modifying the old snapshot, for instance with
+// PersistentInsert. Updates run one at a time.
+func (t *AtomicBST) Update(fn func(old *BasicBST) *BasicBST) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.root.Store(fn(t.root.Load()))
+}
+
+// Insert is shorthand for an Update inserting a key, value pair.
+func (t *AtomicBST) Insert(k KeyType, v interface{}) {
+	t.Update(func(old *BasicBST) *BasicBST {
+		return old.PersistentInsert(k, v)
+	})
+}
@@ -0,0 +1,67 @@
+package bst
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestAtomic(t *testing.T) {
+	const size, readers = 500, 8
+	a := NewAtomic()
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := 0
+			for {
+				s := a.Load()
+				n := s.Len()
+				if n < last {
+					t.Errorf("snapshot went back from %d to %d keys", last, n)
+					return
+				}
+				// The writer inserts 0, 1, 2, ... in turn, so a snapshot
+				// of n keys holds exactly 0..n-1.
+				if n > 0 && s.Get(iKey(n-1)) == nil || s.Get(iKey(n)) != nil {
+					t.Errorf("inconsistent snapshot of %d keys", n)
+					return
+				}
+				if v := s.Version(); v < uint64(n) {
+					t.Errorf("bad version of %d keys: %d", n, v)
+					return
+				}
+				last = n
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		}()
+	}
+	for k := 0; k < size; k++ {
+		if k%2 == 0 {
+			a.Insert(iKey(k), -k)
+			continue
+		}
+		a.Update(func(old *BasicBST) *BasicBST {
+			return old.PersistentInsert(iKey(k), -k)
+		})
+	}
+	close(done)
+	wg.Wait()
+	s := a.Load()
+	if s.Len() != size {
+		t.Errorf("bad final Len: got %d, want %d", s.Len(), size)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("bad final tree: %v", err)
+	}
+	changed := 0
+	s.ChangedSince(0, func(*BasicBST) { changed++ })
+	if changed != size {
+		t.Errorf("bad changed count: got %d, want %d", changed, size)
+	}
+}
@@ -5,15 +5,21 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"strings"
 )
 
-// AVL is a basic unoptimised unbalanced BST.
+// AVL is a BST kept height-balanced by rotations.
 type AVL struct {
 	Key    KeyType
 	Value  interface{}
 	Parent *AVL
 	Child  [2]*AVL // index is oneof {lo, hi}
 	Height int
+	info   *treeInfo
+
+	// Meta holds user metadata for the node's key, allocated by SetMeta.
+	// It is ignored by ordering and balancing, and moves with the key.
+	Meta map[string]interface{}
 }
 
 func (n *AVL) height() int {
@@ -30,71 +36,158 @@ func (n *AVL) updateHeight() int {
 	return n.height()
 }
 
+// RecomputeHeights sets every Height in n's subtree from its children's in
+// one post-order pass, repairing any stale after manual edits, and returns
+// the subtree's height.
+func (n *AVL) RecomputeHeights() int {
+	switch {
+	case n == nil:
+		return -1
+	case n.IsSentinel():
+		return n.Child[lo].RecomputeHeights()
+	}
+	n.Height = 1 + imax(n.Child[lo].RecomputeHeights(), n.Child[hi].RecomputeHeights())
+	return n.Height
+}
+
 func (n *AVL) IsSentinel() bool {
 	return n != nil && n.Parent == n
 }
 
+// less compares two keys, counting the comparison in the tree's Stats.
+func (n *AVL) less(a, b KeyType) bool {
+	n.info.compared()
+	return a.Less(b)
+}
+
+// Stats returns the work counted by the tree since it was made or last reset.
+func (n *AVL) Stats() Stats {
+	return n.info.snapshot()
+}
+
+// ResetStats zeroes the tree's Stats.
+func (n *AVL) ResetStats() {
+	n.info.reset()
+}
+
 // NewAVL allocates a new BasiccBST.
 func NewAVL() *AVL {
-	sentinel := &AVL{}
+	sentinel := &AVL{info: &treeInfo{}}
 	sentinel.Parent = sentinel
 	return sentinel
 }
 
-// Get retrieves a pointer to a AVL node for a given key.
-func (n *AVL) Get(k KeyType) *AVL {
-	switch {
-	case n == nil:
-		return nil
-	case n.IsSentinel() || k.Less(n.Key):
-		return n.Child[lo].Get(k)
-	case n.Key.Less(k):
-		return n.Child[hi].Get(k)
-	default:
-		return n
+// NewAVLSized allocates a new AVL whose first size nodes are drawn from a
+// single preallocated arena rather than allocated one by one.
+func NewAVLSized(size int) *AVL {
+	sentinel := NewAVL()
+	sentinel.info.avls = make([]AVL, size)
+	return sentinel
+}
+
+// Clear removes every key from the tree. A tree made by NewAVLSized recycles
+// its arena for later inserts, so nodes obtained before Clear must not be
+// used after it.
+func (n *AVL) Clear() {
+	if !n.IsSentinel() {
+		return
 	}
+	n.Child[lo] = nil
+	n.info.recycle()
 }
 
-// Visit visits the BST nodes in tree order.
-func (n *AVL) Visit(f func(n *AVL) error) error {
-	if n == nil {
+// NewAVLFromSorted builds a perfectly balanced AVL from pairs, which must be
+// sorted by strictly increasing key, in O(n) and without any rotations.
+func NewAVLFromSorted(pairs []Pair) *AVL {
+	sentinel := NewAVL()
+	sentinel.Child[lo] = buildAVL(pairs, sentinel)
+	return sentinel
+}
+
+// buildAVL returns a perfectly balanced subtree holding the sorted pairs.
+func buildAVL(pairs []Pair, parent *AVL) *AVL {
+	if len(pairs) == 0 {
 		return nil
 	}
+	m := len(pairs) / 2
+	n := &AVL{
+		Key:    pairs[m].Key,
+		Value:  pairs[m].Value,
+		Parent: parent,
+		info:   parent.info,
+	}
+	n.Child[lo] = buildAVL(pairs[:m], n)
+	n.Child[hi] = buildAVL(pairs[m+1:], n)
+	n.updateHeight()
+	return n
+}
+
+// Get retrieves a pointer to a AVL node for a given key.
+// It returns nil if the key is absent, and never returns the sentinel.
+// It loops rather than recursing, so it runs in constant stack space.
+func (n *AVL) Get(k KeyType) *AVL {
 	if n.IsSentinel() {
-		return n.Child[lo].Visit(f)
+		n = n.Child[lo]
 	}
-	if n.Child[lo] != nil {
-		if err := n.Child[lo].Visit(f); err != nil {
-			return err
+	for n != nil {
+		switch {
+		case n.less(k, n.Key):
+			n = n.Child[lo]
+		case n.less(n.Key, k):
+			n = n.Child[hi]
+		default:
+			return n
 		}
 	}
-	if err := f(n); err != nil {
-		return err
+	return nil
+}
+
+// Lookup returns the value for a given key and whether the key is present,
+// without exposing the tree's nodes.
+func (n *AVL) Lookup(k KeyType) (interface{}, bool) {
+	if f := n.Get(k); f != nil {
+		return f.Value, true
 	}
-	if n.Child[hi] != nil {
-		if err := n.Child[hi].Visit(f); err != nil {
+	return nil, false
+}
+
+// Visit visits the BST nodes in tree order, keeping an explicit stack
+// rather than recursing.
+func (n *AVL) Visit(f func(n *AVL) error) error {
+	return n.visitBounded(nil, nil, func(n *AVL, _, _ KeyType) error {
+		return f(n)
+	})
+}
+
+// VisitContext visits the BST nodes in tree order like Visit, but stops and
+// returns ctx.Err() as soon as the context is done.
+func (n *AVL) VisitContext(ctx context.Context, f func(n *AVL) error) error {
+	return n.Visit(func(n *AVL) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
-	}
-	return nil
+		return f(n)
+	})
 }
 
-// Viz writes a DOT visualisation of the graph to an io.Writer
+// Viz writes a DOT visualisation of the graph to an io.Writer. Each node is
+// declared under its quoted key and labelled key(height).
 func (n *AVL) Viz(iow io.Writer) {
 	iow.Write([]byte("digraph treemap {\n"))
 	defer iow.Write([]byte("}\n"))
 	n.Child[lo].Visit(func(n *AVL) error {
 		if n != nil {
+			label := fmt.Sprintf("%s(%d)", n.Key.String(), n.height())
+			text := fmt.Sprintf("  %q [label=%q];\n", n.Key.String(), label)
+			iow.Write([]byte(text))
 			if n.Child[lo] != nil {
-				text := fmt.Sprintf("  %s(%d):w -> %s(%d):n [label=\"lo\"];\n",
-					n.Key.String(), n.height(),
-					n.Child[lo].Key.String(), n.Child[lo].height())
+				text := fmt.Sprintf("  %q:w -> %q:n [label=\"lo\"];\n",
+					n.Key.String(), n.Child[lo].Key.String())
 				iow.Write([]byte(text))
 			}
 			if n.Child[hi] != nil {
-				text := fmt.Sprintf("  %s(%d):e -> %s(%d):n [label=\"hi\"];\n",
-					n.Key.String(), n.height(),
-					n.Child[hi].Key.String(), n.Child[hi].height())
+				text := fmt.Sprintf("  %q:e -> %q:n [label=\"hi\"];\n",
+					n.Key.String(), n.Child[hi].Key.String())
 				iow.Write([]byte(text))
 			}
 		}
@@ -102,6 +195,47 @@ func (n *AVL) Viz(iow io.Writer) {
 	})
 }
 
+// BalanceHistogram counts the nodes by balance factor, i.e. the height of
+// their lo subtree less that of their hi subtree, as stored.
+func (n *AVL) BalanceHistogram() map[int]int {
+	hist := make(map[int]int)
+	n.Visit(func(n *AVL) error {
+		hist[n.Child[lo].height()-n.Child[hi].height()]++
+		return nil
+	})
+	return hist
+}
+
+// String renders the tree sideways as indented text, hi side on top, with
+// each node shown as key(height).
+func (n *AVL) String() string {
+	var b strings.Builder
+	n.render(&b, 0)
+	return b.String()
+}
+
+func (n *AVL) render(b *strings.Builder, depth int) {
+	switch {
+	case n == nil:
+		return
+	case n.IsSentinel():
+		n.Child[lo].render(b, depth)
+		return
+	}
+	n.Child[hi].render(b, depth+1)
+	fmt.Fprintf(b, "%s%s(%d)\n", strings.Repeat("    ", depth), n.Key.String(), n.height())
+	n.Child[lo].render(b, depth+1)
+}
+
+// MapValues replaces each node's Value with f's result, visiting the nodes
+// from low to high. Keys and tree shape are unchanged.
+func (n *AVL) MapValues(f func(k KeyType, v interface{}) interface{}) {
+	n.Visit(func(n *AVL) error {
+		n.Value = f(n.Key, n.Value)
+		return nil
+	})
+}
+
 // Keys returns a channel to stream the keys from low to high.
 func (n *AVL) Keys(ctx context.Context) chan KeyType {
 	keys := make(chan KeyType)
@@ -119,16 +253,17 @@ func (n *AVL) Keys(ctx context.Context) chan KeyType {
 	return keys
 }
 
-// Check returns a channel of nodes violating the BST condition.
+// Check returns a channel of nodes violating the BST condition or the AVL
+// balance condition, or whose stored Height disagrees with their children's.
 func (n *AVL) Check(ctx context.Context) chan *AVL {
 	nodes := make(chan *AVL)
 	go func() {
 		defer close(nodes)
-		n.Visit(func(n *AVL) error {
-			badLo := (n.Child[lo] != nil && !n.Child[lo].Key.Less(n.Key))
-			badHi := (n.Child[hi] != nil && !n.Key.Less(n.Child[hi].Key))
-			badBal := (n.Child[lo].height() - n.Child[hi].height())
-			if badLo || badHi {
+		n.visitBounded(nil, nil, func(n *AVL, below, above KeyType) error {
+			badOrder := boundsError(n.Key, below, above) != nil
+			badBal := iabs(n.Child[lo].height()-n.Child[hi].height()) > 1
+			badHeight := n.Height != 1+imax(n.Child[lo].height(), n.Child[hi].height())
+			if badOrder || badBal || badHeight {
 				select {
 				case nodes <- n:
 					return nil
@@ -142,37 +277,160 @@ func (n *AVL) Check(ctx context.Context) chan *AVL {
 	return nodes
 }
 
-// Insert inserts a key, value pair into the BST.
+// Validate returns an error describing the first node found violating the
+// BST condition, the AVL balance condition, or holding a stale Height, or nil
+// if the tree is valid.
+func (n *AVL) Validate() error {
+	return n.visitBounded(nil, nil, func(n *AVL, below, above KeyType) error {
+		if err := boundsError(n.Key, below, above); err != nil {
+			return err
+		}
+		if b := n.Child[lo].height() - n.Child[hi].height(); iabs(b) > 1 {
+			return fmt.Errorf("node %s: balance %d is out of range", n.Key, b)
+		}
+		if h := 1 + imax(n.Child[lo].height(), n.Child[hi].height()); n.Height != h {
+			return fmt.Errorf("node %s: stored height %d, want %d", n.Key, n.Height, h)
+		}
+		return nil
+	})
+}
+
+// visitBounded visits the BST nodes in tree order, passing each node the
+// keys of the nearest ancestors it should lie above and below, or nil where
+// its subtree is unbounded. Like BasicBST's inorder it keeps an explicit
+// stack, so its depth is not limited by the goroutine stack.
+func (n *AVL) visitBounded(below, above KeyType, f func(n *AVL, below, above KeyType) error) error {
+	type frame struct {
+		n            *AVL
+		below, above KeyType
+	}
+	if n != nil && n.IsSentinel() {
+		n = n.Child[lo]
+	}
+	var stack []frame
+	for n != nil || len(stack) > 0 {
+		for ; n != nil; n, above = n.Child[lo], n.Key {
+			stack = append(stack, frame{n, below, above})
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if err := f(top.n, top.below, top.above); err != nil {
+			return err
+		}
+		n, below, above = top.n.Child[hi], top.n.Key, top.above
+	}
+	return nil
+}
+
+// Insert inserts a key, value pair into the BST. It loops down from the
+// sentinel rather than recursing, then restores balance on the way back up
+// to the root. On a node no longer in a tree it inserts nothing.
 func (n *AVL) Insert(k KeyType, v interface{}) {
-	switch {
-	case n.IsSentinel() || k.Less(n.Key):
-		if n.Child[lo] == nil {
-			n.Child[lo] = &AVL{
-				Key:    k,
-				Value:  v,
-				Parent: n,
-			}
-		} else {
-			n.Child[lo].Insert(k, v)
-		}
-	case n.Key.Less(k):
-		if n.Child[hi] == nil {
-			n.Child[hi] = &AVL{
-				Key:    k,
-				Value:  v,
-				Parent: n,
-			}
-		} else {
-			n.Child[hi].Insert(k, v)
+	n.InsertR(k, v)
+}
+
+// InsertR is like Insert, but also reports whether restoring balance took
+// any rotation.
+func (n *AVL) InsertR(k KeyType, v interface{}) bool {
+	n = n.top()
+	if n == nil {
+		return false
+	}
+	for {
+		var d int
+		switch {
+		case n.IsSentinel() || n.less(k, n.Key):
+			d = lo
+		case n.less(n.Key, k):
+			d = hi
+		default:
+			n.Value = v
+			return false
 		}
-	default:
-		n.Value = v
+		if n.Child[d] == nil {
+			n.Child[d] = n.leaf(k, v)
+			return n.fixUp()
+		}
+		n = n.Child[d]
+	}
+}
+
+// GetOrInsert returns the node holding k and false if k is present, leaving
+// its value alone. Otherwise it inserts the pair and returns the new node and
+// true. Like Insert, it searches from the sentinel, and on a node no longer
+// in a tree it returns nil and false.
+func (n *AVL) GetOrInsert(k KeyType, v interface{}) (*AVL, bool) {
+	n = n.top()
+	if n == nil {
+		return nil, false
+	}
+	for {
+		var d int
+		switch {
+		case n.IsSentinel() || n.less(k, n.Key):
+			d = lo
+		case n.less(n.Key, k):
+			d = hi
+		default:
+			return n, false
+		}
+		if n.Child[d] == nil {
+			c := n.leaf(k, v)
+			n.Child[d] = c
+			n.fixUp()
+			return c, true
+		}
+		n = n.Child[d]
+	}
+}
+
+// top returns the sentinel of n's tree, or nil if n is no longer in a tree,
+// as after Delete.
+func (n *AVL) top() *AVL {
+	for n != nil && !n.IsSentinel() {
+		n = n.Parent
+	}
+	return n
+}
+
+// Update replaces the value for k with f's result and returns true if k is
+// present. Otherwise it returns false and inserts nothing.
+func (n *AVL) Update(k KeyType, f func(old interface{}) interface{}) bool {
+	c := n.Get(k)
+	if c == nil {
+		return false
 	}
+	c.Value = f(c.Value)
+	return true
 }
 
-// which returns the node's index from its parent.
+// InsertAll inserts each of the key, value pairs into the BST in order, so a
+// later pair overwrites an earlier one with an equal key.
+func (n *AVL) InsertAll(pairs []Pair) {
+	for _, p := range pairs {
+		n.Insert(p.Key, p.Value)
+	}
+}
+
+// leaf returns a new childless node under n, drawn from the tree's arena
+// while it lasts.
+func (n *AVL) leaf(k KeyType, v interface{}) *AVL {
+	c := n.info.allocAVL()
+	*c = AVL{
+		Key:    k,
+		Value:  v,
+		Parent: n,
+		info:   n.info,
+	}
+	return c
+}
+
+// which returns the node's index from its parent, or -1 if it is not a
+// child of its parent or has none.
 func (n *AVL) which() int {
 	switch p := n.Parent; {
+	case p == nil:
+		return -1
 	case n == p.Child[lo]:
 		return lo
 	case n == p.Child[hi]:
@@ -182,13 +440,12 @@ func (n *AVL) which() int {
 	}
 }
 
-// opposite reverses a direction.
-func opposite(d int) int {
-	return (d + 1) % 2
-}
-
-// next returns the next tree node in the given direction.
+// next returns the next tree node in the given direction, or nil for the
+// sentinel and for nodes no longer linked into a tree.
 func (n *AVL) next(d int) *AVL {
+	if n == nil || n.IsSentinel() || n.which() < 0 {
+		return nil
+	}
 	r := opposite(d)
 	if n.Child[d] != nil {
 		cur := n.Child[d]
@@ -224,10 +481,12 @@ func (n *AVL) Delete() {
 		return
 	case n == nil:
 		return
+	case n.Parent == nil:
+		return // already deleted
 	case n.Child[hi] == nil:
-		n.Parent.Child[n.which()] = n.Child[lo]
+		n.splice(n.Child[lo])
 	case n.Child[lo] == nil:
-		n.Parent.Child[n.which()] = n.Child[hi]
+		n.splice(n.Child[hi])
 	default:
 		cur := n.Child[hi]
 		for cur.Child[lo] != nil {
@@ -235,10 +494,169 @@ func (n *AVL) Delete() {
 		}
 		n.Key = cur.Key
 		n.Value = cur.Value
+		n.Meta = cur.Meta
 		cur.Delete()
 	}
 }
 
+// PopMin removes the lowest key from the tree, rebalancing, and returns it
+// with its value, or returns false if the tree is empty.
+func (n *AVL) PopMin() (KeyType, interface{}, bool) {
+	return n.pop(lo)
+}
+
+// PopMax removes the highest key from the tree, rebalancing, and returns it
+// with its value, or returns false if the tree is empty.
+func (n *AVL) PopMax() (KeyType, interface{}, bool) {
+	return n.pop(hi)
+}
+
+// pop removes the tree's outermost node on side d and returns its pair.
+func (n *AVL) pop(d int) (KeyType, interface{}, bool) {
+	t := n.top()
+	if t == nil || t.Child[lo] == nil {
+		return nil, nil, false
+	}
+	e := t.Child[lo]
+	for e.Child[d] != nil {
+		e = e.Child[d]
+	}
+	k, v := e.Key, e.Value
+	e.Delete()
+	return k, v, true
+}
+
+// ChangeKey gives node, which must belong to n's tree, the key k and returns
+// the node now holding it. If k still lies strictly between the keys of
+// node's neighbours the key is changed in place; otherwise node's pair is
+// deleted and reinserted under k, rebalancing as usual and replacing any
+// pair already holding k.
+func (n *AVL) ChangeKey(node *AVL, k KeyType) *AVL {
+	p, q := node.Prev(), node.Next()
+	if (p == nil || p.Key.Less(k)) && (q == nil || k.Less(q.Key)) {
+		node.Key = k
+		return node
+	}
+	v, meta := node.Value, node.Meta
+	node.Delete()
+	n.Insert(k, v)
+	node = n.Get(k)
+	node.Meta = meta
+	return node
+}
+
+// DeleteRange removes every key in the closed range [from, to], rebalancing
+// as it goes, and returns how many were removed.
+func (n *AVL) DeleteRange(from, to KeyType) int {
+	var keys []KeyType
+	n.Visit(func(n *AVL) error {
+		if !n.Key.Less(from) && !to.Less(n.Key) {
+			keys = append(keys, n.Key)
+		}
+		return nil
+	})
+	for _, k := range keys {
+		n.Get(k).Delete()
+	}
+	return len(keys)
+}
+
+// Trim removes every key outside the closed range [from, to], rebalancing
+// as it goes, and returns how many were removed.
+func (n *AVL) Trim(from, to KeyType) int {
+	var keys []KeyType
+	n.Visit(func(n *AVL) error {
+		if n.Key.Less(from) || to.Less(n.Key) {
+			keys = append(keys, n.Key)
+		}
+		return nil
+	})
+	for _, k := range keys {
+		n.Get(k).Delete()
+	}
+	return len(keys)
+}
+
+// ToBasic returns a balanced BasicBST holding the tree's pairs.
+func (n *AVL) ToBasic() *BasicBST {
+	var pairs []Pair
+	n.Visit(func(n *AVL) error {
+		pairs = append(pairs, Pair{Key: n.Key, Value: n.Value})
+		return nil
+	})
+	t := NewBasic()
+	t.Child[lo] = buildBasic(pairs, t)
+	return t
+}
+
+// splice replaces n with c in n's parent, then restores the heights and
+// balance of the nodes above it. It clears n's links, value and metadata, so
+// that a caller still holding n does not keep them reachable.
+func (n *AVL) splice(c *AVL) {
+	p := n.Parent
+	p.Child[n.which()] = c
+	if c != nil {
+		c.Parent = p
+	}
+	n.Value, n.Meta = nil, nil
+	n.Parent, n.Child = nil, [2]*AVL{}
+	p.fixUp()
+}
+
+// fixUp restores the heights and balance from n up to the root, returning
+// whether any rotation was needed.
+func (n *AVL) fixUp() bool {
+	rotated := false
+	for !n.IsSentinel() {
+		n.updateHeight()
+		r := n.rebalance()
+		rotated = rotated || r != n
+		n = r.Parent
+	}
+	return rotated
+}
+
+// rotate moves n down to side d, lifting its child on the opposite side into
+// its place, and returns the lifted child.
+func (n *AVL) rotate(d int) *AVL {
+	r := opposite(d)
+	p, w, c := n.Parent, n.which(), n.Child[r]
+	n.Child[r] = c.Child[d]
+	if n.Child[r] != nil {
+		n.Child[r].Parent = n
+	}
+	c.Child[d] = n
+	n.Parent = c
+	c.Parent = p
+	p.Child[w] = c
+	n.updateHeight()
+	c.updateHeight()
+	n.info.rotated()
+	return c
+}
+
+// rebalance restores the AVL condition at n, whose children are balanced,
+// and returns the root of the resulting subtree.
+func (n *AVL) rebalance() *AVL {
+	if n.IsSentinel() {
+		return n
+	}
+	switch b := n.Child[lo].height() - n.Child[hi].height(); {
+	case b > 1:
+		if c := n.Child[lo]; c.Child[lo].height() < c.Child[hi].height() {
+			c.rotate(lo)
+		}
+		return n.rotate(hi)
+	case b < -1:
+		if c := n.Child[hi]; c.Child[hi].height() < c.Child[lo].height() {
+			c.rotate(hi)
+		}
+		return n.rotate(lo)
+	default:
+		return n
+	}
+}
+
 func imax(a, b int) int {
 	if b > a {
 		return b
@@ -246,6 +664,13 @@ func imax(a, b int) int {
 	return a
 }
 
+func imin(a, b int) int {
+	if b < a {
+		return b
+	}
+	return a
+}
+
 func iabs(k int) int {
 	if k < 0 {
 		return -k
@@ -0,0 +1,427 @@
+package bst
+
+import (
+	"context"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAVLGetZeroKey(t *testing.T) {
+	s := NewAVL()
+	s.Insert(iKey(7), -7)
+	if n := s.Get(iKey(0)); n != nil {
+		t.Errorf("unexpected match for absent zero key: %+v", *n)
+	}
+	s.Insert(iKey(0), 0)
+	n := s.Get(iKey(0))
+	switch {
+	case n == nil:
+		t.Errorf("missing zero key")
+	case n.IsSentinel():
+		t.Errorf("Get returned the sentinel")
+	case n.Key.(iKey) != 0:
+		t.Errorf("bad key: got %v, want 0", n.Key)
+	}
+}
+
+func TestAVLString(t *testing.T) {
+	s := NewAVL()
+	for _, k := range [...]int{2, 1, 3} {
+		s.Insert(iKey(k), -k)
+	}
+	want := "    3(0)\n2(1)\n    1(0)\n"
+	if got := s.String(); got != want {
+		t.Errorf("bad String:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAVLValidate(t *testing.T) {
+	s := NewAVL()
+	for _, k := range [...]int{2, 1, 3, 4} {
+		s.Insert(iKey(k), -k)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("unexpected error on valid tree: %v", err)
+	}
+	t.Run("Height", func(t *testing.T) {
+		n := s.Get(iKey(1))
+		n.Height = 5
+		defer n.updateHeight()
+		err := s.Validate()
+		if err == nil || !strings.Contains(err.Error(), "node 1:") {
+			t.Errorf("bad error for stale height: %v", err)
+		}
+	})
+	t.Run("Balance", func(t *testing.T) {
+		four := s.Get(iKey(4))
+		four.Child[hi] = &AVL{Key: iKey(5), Value: -5, Parent: four}
+		for n := four; !n.IsSentinel(); n = n.Parent {
+			n.updateHeight()
+		}
+		err := s.Validate()
+		if err == nil || !strings.Contains(err.Error(), "node 2:") {
+			t.Errorf("bad error for unbalanced tree: %v", err)
+		}
+	})
+}
+
+func TestAVLRandomInsertDelete(t *testing.T) {
+	s := NewAVL()
+	keys := rand.Perm(1000)
+	for _, k := range keys {
+		s.Insert(iKey(k), -k)
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("invalid after inserts: %v", err)
+	}
+	if h := s.Child[lo].height(); h > 14 {
+		t.Errorf("bad height for 1000 keys: %d", h)
+	}
+	for _, k := range keys[:500] {
+		s.Get(iKey(k)).Delete()
+		if err := s.Validate(); err != nil {
+			t.Fatalf("invalid after deleting %d: %v", k, err)
+		}
+	}
+	for _, k := range keys[500:] {
+		if v, ok := s.Lookup(iKey(k)); !ok || v.(int) != -k {
+			t.Errorf("bad Lookup(%d) after deletes: %v, %v", k, v, ok)
+		}
+	}
+}
+
+func TestAVLGetOrInsert(t *testing.T) {
+	s := NewAVL()
+	for k := 0; k < 100; k++ {
+		n, created := s.GetOrInsert(iKey(k), -k)
+		if !created || n.Key.(iKey) != iKey(k) {
+			t.Errorf("bad created node for %d: %+v", k, *n)
+		}
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid after GetOrInsert: %v", err)
+	}
+	n, created := s.GetOrInsert(iKey(50), 0)
+	if created || n.Value.(int) != -50 {
+		t.Errorf("bad existing node: %+v, %v", *n, created)
+	}
+}
+
+func TestAVLCheckHeight(t *testing.T) {
+	s := NewAVL()
+	for k := 0; k < 31; k++ {
+		s.Insert(iKey(k), -k)
+	}
+	ctx := context.Background()
+	for n := range s.Check(ctx) {
+		t.Errorf("violating node in valid tree: %v", n.Key)
+	}
+	root := s.Child[lo]
+	root.Height++
+	var bad []*AVL
+	for n := range s.Check(ctx) {
+		bad = append(bad, n)
+	}
+	if len(bad) != 1 || bad[0] != root {
+		t.Errorf("bad violations for stale root height: got %d nodes, want only the root", len(bad))
+	}
+	root.Height--
+	// Lowering an interior node's height leaves its parent consistent, as
+	// the node's sibling is as tall as the node really is.
+	inner := root.Child[lo].Child[hi]
+	inner.Height--
+	bad = bad[:0]
+	for n := range s.Check(ctx) {
+		bad = append(bad, n)
+	}
+	if len(bad) != 1 || bad[0] != inner {
+		t.Errorf("bad violations for stale interior height: got %d nodes, want only %v", len(bad), inner.Key)
+	}
+}
+
+func TestAVLRecomputeHeights(t *testing.T) {
+	s := NewAVL()
+	for _, k := range rand.Perm(100) {
+		s.Insert(iKey(k), -k)
+	}
+	s.Visit(func(n *AVL) error {
+		n.Height = 0
+		return nil
+	})
+	var depth func(n *AVL) int
+	depth = func(n *AVL) int {
+		if n == nil {
+			return -1
+		}
+		return 1 + imax(depth(n.Child[lo]), depth(n.Child[hi]))
+	}
+	if got, want := s.RecomputeHeights(), depth(s.Child[lo]); got != want {
+		t.Errorf("bad root height: got %d, want %d", got, want)
+	}
+	s.Visit(func(n *AVL) error {
+		if want := depth(n); n.Height != want {
+			t.Errorf("bad Height of %v: got %d, want %d", n.Key, n.Height, want)
+		}
+		return nil
+	})
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid after RecomputeHeights: %v", err)
+	}
+}
+
+func TestAVLInsertR(t *testing.T) {
+	s := NewAVL()
+	for k := 0; k < 64; k++ {
+		before := s.Stats().Rotations
+		got := s.InsertR(iKey(k), -k)
+		if rotated := s.Stats().Rotations > before; got != rotated {
+			t.Errorf("bad InsertR(%d): got %v, but rotated %v", k, got, rotated)
+		}
+		// Ascending inserts only stay balanced unaided when they complete a
+		// perfect tree, i.e. when k+1 is a power of two.
+		if want := (k+1)&k != 0; got != want {
+			t.Errorf("bad InsertR(%d): got %v, want %v", k, got, want)
+		}
+	}
+	if s.InsertR(iKey(10), 0) {
+		t.Errorf("rotation reported for an update")
+	}
+}
+
+func TestAVLPop(t *testing.T) {
+	s := NewAVL()
+	for _, k := range rand.Perm(100) {
+		s.Insert(iKey(k), -k)
+	}
+	for want := 0; want < 50; want++ {
+		k, v, ok := s.PopMin()
+		if !ok || k != iKey(want) || v != -want {
+			t.Fatalf("bad PopMin: got %v/%v/%v, want %d", k, v, ok, want)
+		}
+		if err := s.Validate(); err != nil {
+			t.Fatalf("invalid after PopMin: %v", err)
+		}
+	}
+	for want := 99; want >= 50; want-- {
+		if k, _, ok := s.PopMax(); !ok || k != iKey(want) {
+			t.Fatalf("bad PopMax: got %v/%v, want %d", k, ok, want)
+		}
+	}
+	if _, _, ok := s.PopMin(); ok {
+		t.Errorf("PopMin succeeded on an empty tree")
+	}
+}
+
+func TestBalanceHistogram(t *testing.T) {
+	a, b := NewAVL(), NewBasic()
+	for k := 0; k < 100; k++ {
+		a.Insert(iKey(k), -k)
+		b.Insert(iKey(k%10*10+k/10), -k)
+	}
+	count := 0
+	for f, c := range a.BalanceHistogram() {
+		if f < -1 || f > 1 {
+			t.Errorf("bad AVL balance factor %d on %d nodes", f, c)
+		}
+		count += c
+	}
+	if count != 100 {
+		t.Errorf("bad AVL node count: got %d, want 100", count)
+	}
+	hist := b.BalanceHistogram()
+	lowest, highest, count := 0, 0, 0
+	for f, c := range hist {
+		lowest, highest = imin(lowest, f), imax(highest, f)
+		count += c
+	}
+	if count != 100 || highest-lowest < 10 {
+		t.Errorf("bad degenerate histogram: %v", hist)
+	}
+}
+
+func TestAVLDeleteRange(t *testing.T) {
+	s := NewAVL()
+	for _, k := range rand.Perm(100) {
+		s.Insert(iKey(k), -k)
+	}
+	if got := s.DeleteRange(iKey(30), iKey(60)); got != 31 {
+		t.Errorf("bad DeleteRange count: got %d, want 31", got)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid after DeleteRange: %v", err)
+	}
+	left := 0
+	for range s.Keys(context.Background()) {
+		left++
+	}
+	if left != 69 {
+		t.Errorf("bad key count after DeleteRange: got %d, want 69", left)
+	}
+}
+
+func TestAVLTrim(t *testing.T) {
+	s := NewAVL()
+	for _, k := range rand.Perm(100) {
+		s.Insert(iKey(k), -k)
+	}
+	if got := s.Trim(iKey(25), iKey(75)); got != 49 {
+		t.Errorf("bad Trim count: got %d, want 49", got)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid after Trim: %v", err)
+	}
+	left := 0
+	for range s.Keys(context.Background()) {
+		left++
+	}
+	if left != 51 {
+		t.Errorf("bad key count after Trim: got %d, want 51", left)
+	}
+}
+
+func TestAVLChangeKey(t *testing.T) {
+	s := NewAVL()
+	for _, k := range rand.Perm(100) {
+		s.Insert(iKey(10*k), -k)
+	}
+	t.Run("in place", func(t *testing.T) {
+		n := s.Get(iKey(500))
+		if got := s.ChangeKey(n, iKey(505)); got != n {
+			t.Errorf("bad node: got %v, want it changed in place", got.Key)
+		}
+	})
+	t.Run("moved", func(t *testing.T) {
+		n := s.ChangeKey(s.Get(iKey(505)), iKey(1005))
+		if n == nil || n.Key != iKey(1005) || n.Value != -50 {
+			t.Fatalf("bad moved node: %+v", n)
+		}
+		if s.Get(iKey(505)) != nil {
+			t.Errorf("old key still present")
+		}
+	})
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid after ChangeKey: %v", err)
+	}
+	var last KeyType
+	count := 0
+	for k := range s.Keys(context.Background()) {
+		if last != nil && !last.Less(k) {
+			t.Errorf("bad order: %v before %v", last, k)
+		}
+		last = k
+		count++
+	}
+	if count != 100 || last != iKey(1005) {
+		t.Errorf("bad keys: got %d ending %v, want 100 ending 1005", count, last)
+	}
+}
+
+func TestNewAVLFromSorted(t *testing.T) {
+	pairs := make([]Pair, 1023)
+	for k := range pairs {
+		pairs[k] = Pair{Key: iKey(k), Value: -k}
+	}
+	s := NewAVLFromSorted(pairs)
+	if h := s.Child[lo].height(); h != 9 {
+		t.Errorf("bad height: got %d, want 9", h)
+	}
+	if r := s.Stats().Rotations; r != 0 {
+		t.Errorf("bad rotation count: got %d, want 0", r)
+	}
+	for n := range s.Check(context.Background()) {
+		t.Errorf("violating node: %v", n.Key)
+	}
+	for k := range pairs {
+		if v, ok := s.Lookup(iKey(k)); !ok || v.(int) != -k {
+			t.Errorf("bad Lookup(%d): %v, %v", k, v, ok)
+		}
+	}
+	s.Insert(iKey(2000), 0)
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid after insert: %v", err)
+	}
+}
+
+func TestAVLDeepGet(t *testing.T) {
+	const depth = 1000000
+	s := NewAVL()
+	parent, d := s, lo
+	for k := 0; k < depth; k++ {
+		n := &AVL{Key: iKey(k), Value: -k, Parent: parent}
+		parent.Child[d] = n
+		parent, d = n, hi
+	}
+	if n := s.Get(iKey(depth - 1)); n == nil || n.Value.(int) != -(depth-1) {
+		t.Errorf("bad deepest Get")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	b := NewBasic()
+	for k := 0; k < 1000; k++ {
+		b.Insert(iKey(k), -k)
+	}
+	a := b.ToAVL()
+	if err := a.Validate(); err != nil {
+		t.Errorf("invalid AVL: %v", err)
+	}
+	if h := a.Child[lo].height(); h != 9 {
+		t.Errorf("bad AVL height: got %d, want 9", h)
+	}
+	back := a.ToBasic()
+	if back.Height() != 9 {
+		t.Errorf("bad BasicBST height: got %d, want 9", back.Height())
+	}
+	want := b.ToSlice()
+	got := back.ToSlice()
+	if len(got) != len(want) {
+		t.Fatalf("bad length: got %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bad pair at %d: got %+v, want %+v", i, got[i], want[i])
+		}
+		if v, ok := a.Lookup(want[i].Key); !ok || v != want[i].Value {
+			t.Errorf("bad AVL Lookup(%v): %v, %v", want[i].Key, v, ok)
+		}
+	}
+}
+
+func TestAVLViz(t *testing.T) {
+	decl := regexp.MustCompile(`^  ("[^"]*") \[label="[^"]*"\];$`)
+	edge := regexp.MustCompile(`^  ("[^"]*"):[nsew] -> ("[^"]*"):[nsew] \[label="(lo|hi)"\];$`)
+	for _, size := range []int{0, 1, 7} {
+		s := NewAVL()
+		for k := 0; k < size; k++ {
+			s.Insert(iKey(k), -k)
+		}
+		var b strings.Builder
+		s.Viz(&b)
+		lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+		if lines[0] != "digraph treemap {" || lines[len(lines)-1] != "}" {
+			t.Fatalf("bad DOT framing for %d nodes:\n%s", size, b.String())
+		}
+		declared := map[string]bool{}
+		var ends []string
+		for _, l := range lines[1 : len(lines)-1] {
+			if m := decl.FindStringSubmatch(l); m != nil {
+				declared[m[1]] = true
+			} else if m := edge.FindStringSubmatch(l); m != nil {
+				ends = append(ends, m[1], m[2])
+			} else {
+				t.Errorf("bad DOT statement: %q", l)
+			}
+		}
+		for _, id := range ends {
+			if !declared[id] {
+				t.Errorf("edge to undeclared node %s", id)
+			}
+		}
+		edges := len(ends) / 2
+		if len(declared) != size || edges != imax(size-1, 0) {
+			t.Errorf("bad DOT for %d nodes: %d declared, %d edges", size, len(declared), edges)
+		}
+	}
+}
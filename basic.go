@@ -3,8 +3,10 @@ package bst
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 // KeyType is the interface required from BST keys.
@@ -14,6 +16,12 @@ type KeyType interface {
 	String() string
 }
 
+// Pair is a key, value pair held by a tree.
+type Pair struct {
+	Key   KeyType
+	Value interface{}
+}
+
 // enums for the left and right sides of the tree.ba
 const (
 	lo = iota
@@ -26,89 +34,609 @@ type BasicBST struct {
 	Value  interface{}
 	Parent *BasicBST
 	Child  [2]*BasicBST // index is oneof {lo, hi}
+	size   int          // number of nodes in this subtree
+	agg    interface{}  // aggregate of this subtree's values, if enabled
+	ver    uint64       // tree version at which Value was last written
+	id     uint64       // order in which the node was made within its tree
+	info   *treeInfo
+
+	// Meta holds user metadata for the node's key, allocated by SetMeta.
+	// It is ignored by ordering and balancing, and moves with the key.
+	Meta map[string]interface{}
+}
+
+// count returns the number of nodes in the subtree rooted at n.
+func (n *BasicBST) count() int {
+	switch {
+	case n == nil:
+		return 0
+	case n.IsSentinel():
+		return n.Child[lo].count()
+	default:
+		return n.size
+	}
+}
+
+// height computes the height of the subtree rooted at n as its deepest
+// node's depth.
+func (n *BasicBST) height() int {
+	h := -1
+	n.WalkDetailed(func(_ *BasicBST, depth, _ int) error {
+		h = imax(h, depth)
+		return nil
+	})
+	return h
+}
+
+// updateSize recomputes n's size, and aggregate if enabled, from its
+// children's.
+func (n *BasicBST) updateSize() int {
+	if n != nil && !n.IsSentinel() {
+		n.size = 1 + n.Child[lo].count() + n.Child[hi].count()
+		n.info.aggregate(n)
+	}
+	return n.count()
+}
+
+// fixUp updates the sizes from n up to the root.
+func (n *BasicBST) fixUp() {
+	for ; !n.IsSentinel(); n = n.Parent {
+		n.updateSize()
+	}
+}
+
+// refresh updates the sizes throughout n's subtree.
+func (n *BasicBST) refresh() {
+	n.Traverse(PostOrder, func(n *BasicBST) error {
+		n.updateSize()
+		return nil
+	})
 }
 
 func (n *BasicBST) IsSentinel() bool {
 	return n != nil && n.Parent == n
 }
 
+// less compares two keys, counting the comparison in the tree's Stats.
+func (n *BasicBST) less(a, b KeyType) bool {
+	n.info.compared()
+	return a.Less(b)
+}
+
+// Stats returns the work counted by the tree since it was made or last reset.
+func (n *BasicBST) Stats() Stats {
+	return n.info.snapshot()
+}
+
+// ResetStats zeroes the tree's Stats.
+func (n *BasicBST) ResetStats() {
+	n.info.reset()
+}
+
 // NewBasic allocates a new BasiccBST.
 func NewBasic() *BasicBST {
-	sentinel := &BasicBST{}
+	sentinel := &BasicBST{info: &treeInfo{}}
 	sentinel.Parent = sentinel
 	return sentinel
 }
 
+// NewBasicSized allocates a new BasicBST whose first size nodes are drawn
+// from a single preallocated arena rather than allocated one by one.
+func NewBasicSized(size int) *BasicBST {
+	sentinel := NewBasic()
+	sentinel.info.basics = make([]BasicBST, size)
+	return sentinel
+}
+
+// NewBasicPolicy allocates a new BasicBST whose Insert resolves equal keys
+// according to p.
+func NewBasicPolicy(p TiePolicy) *BasicBST {
+	sentinel := NewBasic()
+	sentinel.info.tie = p
+	return sentinel
+}
+
+// NewBasicAggregate allocates a new BasicBST in which every node keeps the
+// aggregate of its subtree's values for SubtreeAggregate: extract maps a
+// value to its contribution, combine merges two contributions, and zero is
+// the aggregate of no values. combine must be associative.
+func NewBasicAggregate(zero interface{}, extract func(v interface{}) interface{}, combine func(a, b interface{}) interface{}) *BasicBST {
+	sentinel := NewBasic()
+	sentinel.info.zero = zero
+	sentinel.info.extract = extract
+	sentinel.info.combine = combine
+	return sentinel
+}
+
+// NewBasicStrict allocates a new BasicBST whose Insert panics on a nil value,
+// including a typed nil such as a nil pointer. Otherwise a stored nil is
+// easily confused with the nil returned for a missing key, or panics later
+// on a type assertion far from where it was inserted.
+func NewBasicStrict() *BasicBST {
+	sentinel := NewBasic()
+	sentinel.info.strict = true
+	return sentinel
+}
+
+// NewBasicScapegoat allocates a new BasicBST that keeps itself balanced as a
+// scapegoat tree: whenever Insert leaves a node deeper than log base
+// 1/alpha of the tree's size, it rebuilds just the subtree of an ancestor
+// whose one side has grown to hold more than alpha of its nodes. Smaller
+// alphas keep the tree shallower at the cost of more rebuilding. It panics
+// unless alpha lies in [0.5, 1).
+func NewBasicScapegoat(alpha float64) *BasicBST {
+	if alpha < 0.5 || alpha >= 1 {
+		panic("bst: scapegoat alpha out of range")
+	}
+	sentinel := NewBasic()
+	sentinel.info.alpha = alpha
+	return sentinel
+}
+
+// Clear removes every key from the tree. A tree made by NewBasicSized
+// recycles its arena for later inserts, so nodes obtained before Clear must
+// not be used after it.
+func (n *BasicBST) Clear() {
+	if !n.IsSentinel() {
+		return
+	}
+	n.Child[lo] = nil
+	n.info.invalidate()
+	n.info.recycle()
+}
+
 // Get retrieves a pointer to a BasicBST node for a given key.
+// It returns nil if the key is absent, and never returns the sentinel.
+// It loops rather than recursing, so even a degenerate tree is searched in
+// constant stack space.
 func (n *BasicBST) Get(k KeyType) *BasicBST {
-	switch {
-	case n == nil:
-		return nil
-	case n.IsSentinel() || k.Less(n.Key):
-		return n.Child[lo].Get(k)
-	case n.Key.Less(k):
-		return n.Child[hi].Get(k)
-	default:
-		return n
+	if n.IsSentinel() {
+		if f, ok := n.smallGet(k); ok {
+			return f
+		}
+		n = n.Child[lo]
 	}
+	for n != nil {
+		switch {
+		case n.less(k, n.Key):
+			n = n.Child[lo]
+		case n.less(n.Key, k):
+			n = n.Child[hi]
+		default:
+			return n
+		}
+	}
+	return nil
 }
 
-// Visit visits the BST nodes in tree order.
-func (n *BasicBST) Visit(f func(n *BasicBST) error) error {
-	if n == nil {
-		return nil
-	}
+// GetPath is like Get, but also returns the nodes visited on the way down,
+// starting with n itself, so with the sentinel when called on the tree, and
+// ending with the found node or, on a miss, the last node compared.
+func (n *BasicBST) GetPath(k KeyType) ([]*BasicBST, *BasicBST) {
+	var path []*BasicBST
 	if n.IsSentinel() {
-		return n.Child[lo].Visit(f)
+		path = append(path, n)
+		n = n.Child[lo]
 	}
-	if n.Child[lo] != nil {
-		if err := n.Child[lo].Visit(f); err != nil {
-			return err
+	for n != nil {
+		path = append(path, n)
+		switch {
+		case n.less(k, n.Key):
+			n = n.Child[lo]
+		case n.less(n.Key, k):
+			n = n.Child[hi]
+		default:
+			return path, n
+		}
+	}
+	return path, nil
+}
+
+// Lookup returns the value for a given key and whether the key is present,
+// without exposing the tree's nodes.
+func (n *BasicBST) Lookup(k KeyType) (interface{}, bool) {
+	if f := n.Get(k); f != nil {
+		return f.Value, true
+	}
+	return nil, false
+}
+
+// GetMany returns the values of those keys that are present, mapped by key.
+// If keys is sorted, the lookups are resolved in one merge-like pass over
+// the tree, in O(n + m) rather than O(m log n); otherwise each key is looked
+// up separately.
+func (n *BasicBST) GetMany(keys []KeyType) map[KeyType]interface{} {
+	found := make(map[KeyType]interface{})
+	sorted := true
+	for i := 1; i < len(keys) && sorted; i++ {
+		sorted = !keys[i].Less(keys[i-1])
+	}
+	if !sorted {
+		for _, k := range keys {
+			if f := n.Get(k); f != nil {
+				found[k] = f.Value
+			}
 		}
+		return found
 	}
-	if err := f(n); err != nil {
+	var cur *BasicBST
+	if len(keys) > 0 {
+		cur = n.LowerBound(keys[0])
+	}
+	for _, k := range keys {
+		for cur != nil && cur.Key.Less(k) {
+			cur = cur.Next()
+		}
+		if cur == nil {
+			break
+		}
+		if cur.Key.Equal(k) {
+			found[k] = cur.Value
+		}
+	}
+	return found
+}
+
+// Visit visits the BST nodes in tree order. Called on the sentinel it visits
+// the whole tree, and on any other node just that node's subtree.
+func (n *BasicBST) Visit(f func(n *BasicBST) error) error {
+	return n.Traverse(InOrder, f)
+}
+
+// Order is a depth-first order in which Traverse visits nodes.
+type Order int
+
+const (
+	PreOrder  Order = iota // each node before its subtrees
+	InOrder                // each node between its lo and hi subtrees
+	PostOrder              // each node after its subtrees
+)
+
+// Traverse visits the BST nodes depth-first in the given order, stopping at
+// and returning the first error from f. Called on the sentinel it visits the
+// whole tree, and on any other node just that node's subtree. Like Visit it
+// keeps an explicit stack rather than recursing.
+func (n *BasicBST) Traverse(order Order, f func(n *BasicBST) error) error {
+	if n != nil && n.IsSentinel() {
+		n = n.Child[lo]
+	}
+	var stack []*BasicBST
+	switch order {
+	case PreOrder:
+		if n != nil {
+			stack = append(stack, n)
+		}
+		for len(stack) > 0 {
+			n = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if err := f(n); err != nil {
+				return err
+			}
+			for _, c := range [...]*BasicBST{n.Child[hi], n.Child[lo]} {
+				if c != nil {
+					stack = append(stack, c)
+				}
+			}
+		}
+		return nil
+	case InOrder:
+		return n.inorder(nil, nil, func(n *BasicBST, _, _ KeyType) error {
+			return f(n)
+		})
+	case PostOrder:
+		var done *BasicBST // the node visited last
+		for n != nil || len(stack) > 0 {
+			for ; n != nil; n = n.Child[lo] {
+				stack = append(stack, n)
+			}
+			top := stack[len(stack)-1]
+			if c := top.Child[hi]; c != nil && c != done {
+				n = c
+				continue
+			}
+			if err := f(top); err != nil {
+				return err
+			}
+			done = top
+			stack = stack[:len(stack)-1]
+		}
+		return nil
+	default:
+		return fmt.Errorf("bad traversal order %d", order)
+	}
+}
+
+// VisitWithNeighbors visits the BST nodes in tree order like Visit, passing
+// each node along with its predecessor and successor, which are nil at the
+// start and the end. It makes a single traversal, calling f one node behind.
+func (n *BasicBST) VisitWithNeighbors(f func(prev, cur, next *BasicBST) error) error {
+	var prev, cur *BasicBST
+	err := n.Visit(func(n *BasicBST) error {
+		if cur != nil {
+			if err := f(prev, cur, n); err != nil {
+				return err
+			}
+		}
+		prev, cur = cur, n
+		return nil
+	})
+	if err != nil || cur == nil {
 		return err
 	}
-	if n.Child[hi] != nil {
-		if err := n.Child[hi].Visit(f); err != nil {
+	return f(prev, cur, nil)
+}
+
+// errStop ends a traversal early without reporting an error.
+var errStop = errors.New("stop")
+
+// TakeWhile visits the BST nodes in tree order like Visit, stopping as soon
+// as f returns false.
+func (n *BasicBST) TakeWhile(f func(n *BasicBST) bool) {
+	n.Visit(func(n *BasicBST) error {
+		if !f(n) {
+			return errStop
+		}
+		return nil
+	})
+}
+
+// VisitContext visits the BST nodes in tree order like Visit, but stops and
+// returns ctx.Err() as soon as the context is done.
+func (n *BasicBST) VisitContext(ctx context.Context, f func(n *BasicBST) error) error {
+	return n.Visit(func(n *BasicBST) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
-	}
-	return nil
+		return f(n)
+	})
 }
 
-// Viz writes a DOT visualisation of the graph to an io.Writer
-func (n *BasicBST) Viz(iow io.Writer) {
+// Viz writes a DOT visualisation of the graph to an io.Writer, filling in
+// the nodes whose keys are among highlight. Every node is declared, so that
+// trees without edges still render, and identified by its ID, labelled with
+// its key, so that the same inserts always give the same output.
+func (n *BasicBST) Viz(iow io.Writer, highlight ...KeyType) {
 	iow.Write([]byte("digraph treemap {\n"))
 	defer iow.Write([]byte("}\n"))
 	n.Child[lo].Visit(func(n *BasicBST) error {
 		if n != nil {
+			style := ""
+			for _, k := range highlight {
+				if n.Key.Equal(k) {
+					style = `, style=filled, fillcolor="yellow"`
+					break
+				}
+			}
+			text := fmt.Sprintf("  n%d [label=%q%s];\n", n.id, n.Key.String(), style)
+			iow.Write([]byte(text))
 			if n.Child[lo] != nil {
-				text := fmt.Sprintf("  %s:w -> %s:n [label=\"lo\"];\n",
-					n.Key.String(), n.Child[lo].Key.String())
+				text := fmt.Sprintf("  n%d:w -> n%d:n [label=\"lo\"];\n",
+					n.id, n.Child[lo].id)
 				iow.Write([]byte(text))
 			}
 			if n.Child[hi] != nil {
-				text := fmt.Sprintf("  %s:e -> %s:n [label=\"hi\"];\n",
-					n.Key.String(), n.Child[hi].Key.String())
+				text := fmt.Sprintf("  n%d:e -> n%d:n [label=\"hi\"];\n",
+					n.id, n.Child[hi].id)
 				iow.Write([]byte(text))
 			}
-			// if !n.Parent.IsSentinel() {
-			// 	text := fmt.Sprintf("  %s -> %s [label=\"parent\", style=dashed];\n",
-			// 		n.Key.String(), n.Parent.Key.String())
-			// 	iow.Write([]byte(text))
-			// }
 		}
 		return nil
 	})
 }
 
+// Height returns the height of the BST, or -1 if it is empty.
+func (n *BasicBST) Height() int {
+	return n.height()
+}
+
+// BalanceHistogram counts the nodes by balance factor, i.e. the height of
+// their lo subtree less that of their hi subtree. A wide spread suggests the
+// tree would benefit from Rebalance.
+func (n *BasicBST) BalanceHistogram() map[int]int {
+	hist := make(map[int]int)
+	heights := n.heights()
+	n.Traverse(InOrder, func(n *BasicBST) error {
+		hist[heights[n.Child[lo]]-heights[n.Child[hi]]]++
+		return nil
+	})
+	return hist
+}
+
+// Len returns the number of keys in the BST.
+func (n *BasicBST) Len() int {
+	return n.count()
+}
+
+// SubtreeSize returns the number of nodes in n's subtree, counting n itself,
+// or 0 for nil. It reads the maintained sizes instead of walking the nodes.
+func (n *BasicBST) SubtreeSize() int {
+	return n.count()
+}
+
+// SubtreeKeys streams the keys of n's subtree from low to high, like Keys.
+// The channel is closed at once for a nil node.
+func (n *BasicBST) SubtreeKeys(ctx context.Context) chan KeyType {
+	return n.Keys(ctx)
+}
+
+// ToSlice returns the key, value pairs of the BST from low to high.
+func (n *BasicBST) ToSlice() []Pair {
+	pairs := make([]Pair, 0, n.Len())
+	n.Visit(func(n *BasicBST) error {
+		pairs = append(pairs, Pair{Key: n.Key, Value: n.Value})
+		return nil
+	})
+	return pairs
+}
+
+// AllKeys returns the keys of the BST from low to high. Unlike Keys it needs
+// no goroutine or channel, but materializes every key at once, in a slice
+// allocated just once using Len.
+func (n *BasicBST) AllKeys() []KeyType {
+	keys := make([]KeyType, 0, n.Len())
+	n.Visit(func(n *BasicBST) error {
+		keys = append(keys, n.Key)
+		return nil
+	})
+	return keys
+}
+
+// String renders the tree sideways as indented text, hi side on top, with
+// each node shown as key(height). An empty tree renders as the empty string.
+// The heights are found in one pass beforehand, so rendering takes linear
+// time whatever the tree's shape.
+func (n *BasicBST) String() string {
+	heights := n.heights()
+	if n.IsSentinel() {
+		n = n.Child[lo]
+	}
+	type frame struct {
+		n     *BasicBST
+		depth int
+	}
+	var b strings.Builder
+	var stack []frame
+	for depth := 0; n != nil || len(stack) > 0; {
+		for ; n != nil; n, depth = n.Child[hi], depth+1 {
+			stack = append(stack, frame{n, depth})
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		fmt.Fprintf(&b, "%s%s(%d)\n", strings.Repeat("    ", top.depth), top.n.Key.String(), heights[top.n])
+		n, depth = top.n.Child[lo], top.depth+1
+	}
+	return b.String()
+}
+
+// heights maps each node of n's subtree, and nil, to its height, visiting
+// the children before their parent.
+func (n *BasicBST) heights() map[*BasicBST]int {
+	h := map[*BasicBST]int{nil: -1}
+	n.Traverse(PostOrder, func(n *BasicBST) error {
+		h[n] = 1 + imax(h[n.Child[lo]], h[n.Child[hi]])
+		return nil
+	})
+	return h
+}
+
+// MapValues replaces each node's Value with f's result, visiting the nodes
+// from low to high. Keys and tree shape are unchanged. On a strict tree it
+// panics at the first nil result, leaving the values before it replaced.
+func (n *BasicBST) MapValues(f func(k KeyType, v interface{}) interface{}) {
+	n.Visit(func(n *BasicBST) error {
+		v := f(n.Key, n.Value)
+		n.info.admit(v)
+		n.Value = v
+		n.ver = n.info.bump()
+		return nil
+	})
+	n.refresh()
+	n.Parent.fixUp()
+}
+
+// WalkDetailed visits the BST nodes in tree order like Visit, also passing
+// each node's depth below the start node and its side (lo or hi) under its
+// parent, or -1 for the start node.
+func (n *BasicBST) WalkDetailed(f func(n *BasicBST, depth int, side int) error) error {
+	if n.IsSentinel() {
+		n = n.Child[lo]
+	}
+	type frame struct {
+		n           *BasicBST
+		depth, side int
+	}
+	var stack []frame
+	for depth, side := 0, -1; n != nil || len(stack) > 0; {
+		for ; n != nil; n, depth, side = n.Child[lo], depth+1, lo {
+			stack = append(stack, frame{n, depth, side})
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if err := f(top.n, top.depth, top.side); err != nil {
+			return err
+		}
+		n, depth, side = top.n.Child[hi], top.depth+1, hi
+	}
+	return nil
+}
+
+// VisitLevelOrder visits the BST nodes breadth first, top down and lo to hi
+// within each level, passing each node's level below the start node.
+func (n *BasicBST) VisitLevelOrder(f func(n *BasicBST, level int) error) error {
+	if n.IsSentinel() {
+		n = n.Child[lo]
+	}
+	if n == nil {
+		return nil
+	}
+	type entry struct {
+		n     *BasicBST
+		level int
+	}
+	queue := []entry{{n: n}}
+	for len(queue) > 0 {
+		e := queue[0]
+		queue = queue[1:]
+		if err := f(e.n, e.level); err != nil {
+			return err
+		}
+		for _, c := range e.n.Child {
+			if c != nil {
+				queue = append(queue, entry{n: c, level: e.level + 1})
+			}
+		}
+	}
+	return nil
+}
+
+// RangeVisit visits in tree order the nodes of n's subtree whose keys lie
+// in the closed range [from, to], skipping the subtrees outside it.
+func (n *BasicBST) RangeVisit(from, to KeyType, f func(n *BasicBST) error) error {
+	return n.inorder(from, to, func(n *BasicBST, _, _ KeyType) error {
+		if n.Key.Less(from) || to.Less(n.Key) {
+			return nil
+		}
+		return f(n)
+	})
+}
+
+// Fold threads acc through f for each pair from low to high and returns the
+// final accumulator.
+func (n *BasicBST) Fold(acc interface{}, f func(acc interface{}, k KeyType, v interface{}) interface{}) interface{} {
+	n.Visit(func(n *BasicBST) error {
+		acc = f(acc, n.Key, n.Value)
+		return nil
+	})
+	return acc
+}
+
 // Keys returns a channel to stream the keys from low to high.
+// Called on a node other than the sentinel it streams just that subtree.
 func (n *BasicBST) Keys(ctx context.Context) chan KeyType {
-	keys := make(chan KeyType)
+	return n.KeysBuffered(ctx, 0)
+}
+
+// KeysBuffered is like Keys, but streams through a channel buffering up to
+// size keys, so that the producer need not wait on the consumer key by key.
+func (n *BasicBST) KeysBuffered(ctx context.Context, size int) chan KeyType {
+	return n.streamKeys(ctx, size, nil)
+}
+
+// KeysWhere is like Keys, but streams only the keys whose pairs satisfy pred.
+func (n *BasicBST) KeysWhere(ctx context.Context, pred func(k KeyType, v interface{}) bool) chan KeyType {
+	return n.streamKeys(ctx, 0, pred)
+}
+
+// streamKeys streams from low to high the keys whose pairs satisfy pred, or
+// all of them if pred is nil, through a channel buffering up to size keys.
+func (n *BasicBST) streamKeys(ctx context.Context, size int, pred func(k KeyType, v interface{}) bool) chan KeyType {
+	keys := make(chan KeyType, size)
 	go func() {
 		defer close(keys)
 		n.Visit(func(n *BasicBST) error {
+			if pred != nil && !pred(n.Key, n.Value) {
+				return nil
+			}
 			select {
 			case keys <- n.Key:
 				return nil
@@ -120,15 +648,36 @@ func (n *BasicBST) Keys(ctx context.Context) chan KeyType {
 	return keys
 }
 
-// Check returns a channel of nodes violating the BST condition.
+// KeysRange streams, from low to high, the keys whose rank lies in
+// [fromRank, toRank). It seeks to fromRank using the subtree sizes rather
+// than walking the keys before it.
+func (n *BasicBST) KeysRange(ctx context.Context, fromRank, toRank int) chan KeyType {
+	keys := make(chan KeyType)
+	go func() {
+		defer close(keys)
+		if fromRank < 0 {
+			fromRank = 0
+		}
+		n.PageVisit(fromRank, toRank-fromRank, func(n *BasicBST) error {
+			select {
+			case keys <- n.Key:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return keys
+}
+
+// Check returns a channel of nodes violating the BST condition, i.e. whose
+// key does not lie between those of the ancestors bounding its subtree.
 func (n *BasicBST) Check(ctx context.Context) chan *BasicBST {
 	nodes := make(chan *BasicBST)
 	go func() {
 		defer close(nodes)
-		n.Visit(func(n *BasicBST) error {
-			badLo := (n.Child[lo] != nil && !n.Child[lo].Key.Less(n.Key))
-			badHi := (n.Child[hi] != nil && !n.Key.Less(n.Child[hi].Key))
-			if badLo || badHi {
+		n.inorder(nil, nil, func(n *BasicBST, below, above KeyType) error {
+			if boundsError(n.Key, below, above) != nil {
 				select {
 				case nodes <- n:
 					return nil
@@ -142,37 +691,189 @@ func (n *BasicBST) Check(ctx context.Context) chan *BasicBST {
 	return nodes
 }
 
-// Insert inserts a key, value pair into the BST.
-func (n *BasicBST) Insert(k KeyType, v interface{}) {
-	switch {
-	case n.IsSentinel() || k.Less(n.Key):
-		if n.Child[lo] == nil {
-			n.Child[lo] = &BasicBST{
-				Key:    k,
-				Value:  v,
-				Parent: n,
+// Validate returns an error describing the first node found violating the
+// BST condition, or nil if the tree is valid.
+func (n *BasicBST) Validate() error {
+	return n.inorder(nil, nil, func(n *BasicBST, below, above KeyType) error {
+		return boundsError(n.Key, below, above)
+	})
+}
+
+// inorder visits n's subtree in tree order using an explicit stack, so
+// that degenerate trees cannot overflow the goroutine stack. Subtrees lying
+// wholly below from or above to are skipped; a nil bound is open. Each node
+// is passed the keys of the nearest ancestors it should lie above and below,
+// or nil where its subtree is unbounded. A non-nil error from f stops the
+// traversal and is returned.
+func (n *BasicBST) inorder(from, to KeyType, f func(n *BasicBST, below, above KeyType) error) error {
+	type frame struct {
+		n            *BasicBST
+		below, above KeyType
+	}
+	if n != nil && n.IsSentinel() {
+		n = n.Child[lo]
+	}
+	var stack []frame
+	var below, above KeyType
+	for {
+		for n != nil {
+			stack = append(stack, frame{n, below, above})
+			if from != nil && !from.Less(n.Key) {
+				break
 			}
-		} else {
-			n.Child[lo].Insert(k, v)
+			n, above = n.Child[lo], n.Key
 		}
-	case n.Key.Less(k):
-		if n.Child[hi] == nil {
-			n.Child[hi] = &BasicBST{
-				Key:    k,
-				Value:  v,
-				Parent: n,
-			}
-		} else {
-			n.Child[hi].Insert(k, v)
+		if len(stack) == 0 {
+			return nil
+		}
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if err := f(top.n, top.below, top.above); err != nil {
+			return err
+		}
+		n = nil
+		if to == nil || top.n.Key.Less(to) {
+			n, below, above = top.n.Child[hi], top.n.Key, top.above
 		}
+	}
+}
+
+// boundsError describes how k fails to lie strictly between below and
+// above, either of which may be nil for no bound, or returns nil.
+func boundsError(k, below, above KeyType) error {
+	switch {
+	case below != nil && !below.Less(k):
+		return fmt.Errorf("node %s: not greater than ancestor %s", k, below)
+	case above != nil && !k.Less(above):
+		return fmt.Errorf("node %s: not less than ancestor %s", k, above)
 	default:
-		n.Value = v
+		return nil
+	}
+}
+
+// Insert inserts a key, value pair into the BST and returns the node holding
+// the key. A key already present is resolved by the tree's TiePolicy, which
+// overwrites by default. Whichever node it is called on, the insert starts
+// from the tree's sentinel, as with InsertFromRoot.
+func (n *BasicBST) Insert(k KeyType, v interface{}) *BasicBST {
+	return n.InsertFromRoot(k, v)
+}
+
+// InsertFromRoot inserts a key, value pair into the tree holding n, starting
+// from its sentinel, so that holding only an internal node cannot place the
+// key out of order. On a node no longer in a tree it inserts nothing and
+// returns nil.
+func (n *BasicBST) InsertFromRoot(k KeyType, v interface{}) *BasicBST {
+	t := n.top()
+	if t == nil {
+		return nil
+	}
+	return t.insert(k, v)
+}
+
+// insert inserts a key, value pair into n's subtree, looping down rather
+// than recursing.
+func (n *BasicBST) insert(k KeyType, v interface{}) *BasicBST {
+	for {
+		var d int
+		switch {
+		case n.IsSentinel() || n.less(k, n.Key):
+			d = lo
+		case n.less(n.Key, k):
+			d = hi
+		default:
+			n.Value = n.info.merge(n.Value, v)
+			n.ver = n.info.bump()
+			n.fixUp()
+			return n
+		}
+		if n.Child[d] == nil {
+			c := n.leaf(k, v)
+			n.Child[d] = c
+			n.fixUp()
+			c.scapegoat()
+			return c
+		}
+		n = n.Child[d]
+	}
+}
+
+// GetOrInsert returns the node holding k and false if k is present, leaving
+// its value alone. Otherwise it inserts the pair and returns the new node and
+// true. Like Insert, it searches from the sentinel, and on a node no longer
+// in a tree it returns nil and false.
+func (n *BasicBST) GetOrInsert(k KeyType, v interface{}) (*BasicBST, bool) {
+	n = n.top()
+	if n == nil {
+		return nil, false
+	}
+	for {
+		var d int
+		switch {
+		case n.IsSentinel() || n.less(k, n.Key):
+			d = lo
+		case n.less(n.Key, k):
+			d = hi
+		default:
+			return n, false
+		}
+		if n.Child[d] == nil {
+			c := n.leaf(k, v)
+			n.Child[d] = c
+			n.fixUp()
+			c.scapegoat()
+			return c, true
+		}
+		n = n.Child[d]
+	}
+}
+
+// Update replaces the value for k with f's result and returns true if k is
+// present. Otherwise it returns false and inserts nothing.
+func (n *BasicBST) Update(k KeyType, f func(old interface{}) interface{}) bool {
+	c := n.Get(k)
+	if c == nil {
+		return false
 	}
+	v := f(c.Value)
+	c.info.admit(v)
+	c.Value = v
+	c.ver = c.info.bump()
+	c.fixUp()
+	return true
 }
 
-// which returns the node's index from its parent.
+// InsertAll inserts each of the key, value pairs into the BST in order, so a
+// later pair overwrites an earlier one with an equal key.
+func (n *BasicBST) InsertAll(pairs []Pair) {
+	for _, p := range pairs {
+		n.Insert(p.Key, p.Value)
+	}
+}
+
+// leaf returns a new childless node under n, drawn from the tree's arena
+// while it lasts.
+func (n *BasicBST) leaf(k KeyType, v interface{}) *BasicBST {
+	n.info.invalidate()
+	c := n.info.allocBasic()
+	*c = BasicBST{
+		Key:    k,
+		Value:  n.info.initial(v),
+		Parent: n,
+		ver:    n.info.bump(),
+		id:     n.info.nextID(),
+		info:   n.info,
+	}
+	c.updateSize()
+	return c
+}
+
+// which returns the node's index from its parent, or -1 if it is not a
+// child of its parent or has none.
 func (n *BasicBST) which() int {
 	switch p := n.Parent; {
+	case p == nil:
+		return -1
 	case n == p.Child[lo]:
 		return lo
 	case n == p.Child[hi]:
@@ -187,8 +888,12 @@ func opposite(d int) int {
 	return (d + 1) % 2
 }
 
-// next returns the next tree node in the given direction.
+// next returns the next tree node in the given direction, or nil for the
+// sentinel and for nodes no longer linked into a tree.
 func (n *BasicBST) next(d int) *BasicBST {
+	if n == nil || n.IsSentinel() || n.which() < 0 {
+		return nil
+	}
 	r := opposite(d)
 	if n.Child[d] != nil {
 		cur := n.Child[d]
@@ -224,10 +929,12 @@ func (n *BasicBST) Delete() {
 		return
 	case n == nil:
 		return
+	case n.Parent == nil:
+		return // already deleted
 	case n.Child[hi] == nil:
-		n.Parent.Child[n.which()] = n.Child[lo]
+		n.splice(n.Child[lo])
 	case n.Child[lo] == nil:
-		n.Parent.Child[n.which()] = n.Child[hi]
+		n.splice(n.Child[hi])
 	default:
 		cur := n.Child[hi]
 		for cur.Child[lo] != nil {
@@ -235,6 +942,658 @@ func (n *BasicBST) Delete() {
 		}
 		n.Key = cur.Key
 		n.Value = cur.Value
+		n.ver = cur.ver
+		n.Meta = cur.Meta
 		cur.Delete()
 	}
 }
+
+// PopMin removes the lowest key from the tree and returns it with its value,
+// or returns false if the tree is empty.
+func (n *BasicBST) PopMin() (KeyType, interface{}, bool) {
+	return n.pop(lo)
+}
+
+// PopMax removes the highest key from the tree and returns it with its
+// value, or returns false if the tree is empty.
+func (n *BasicBST) PopMax() (KeyType, interface{}, bool) {
+	return n.pop(hi)
+}
+
+// pop removes the tree's outermost node on side d and returns its pair.
+func (n *BasicBST) pop(d int) (KeyType, interface{}, bool) {
+	t := n.top()
+	if t == nil || t.Child[lo] == nil {
+		return nil, nil, false
+	}
+	e := t.Child[lo]
+	for e.Child[d] != nil {
+		e = e.Child[d]
+	}
+	k, v := e.Key, e.Value
+	e.Delete()
+	return k, v, true
+}
+
+// DeleteChecked is like Delete, but first checks the Parent links that Delete
+// relies on and returns an error describing a broken one, leaving the tree
+// unchanged, where Delete would panic or corrupt the tree.
+func (n *BasicBST) DeleteChecked() error {
+	if n == nil || n.IsSentinel() {
+		return nil
+	}
+	spliced := n
+	if n.Child[lo] != nil && n.Child[hi] != nil {
+		spliced = n.Child[hi]
+		for spliced.Child[lo] != nil {
+			spliced = spliced.Child[lo]
+		}
+	}
+	if err := spliced.linkError(); err != nil {
+		return err
+	}
+	n.Delete()
+	return nil
+}
+
+// linkError returns an error describing the first node from n up to the
+// sentinel that is not linked as a child of its Parent, or nil.
+func (n *BasicBST) linkError() error {
+	for ; !n.IsSentinel(); n = n.Parent {
+		switch {
+		case n.Parent == nil:
+			return fmt.Errorf("node %s: nil Parent", n.Key)
+		case n.which() < 0:
+			return fmt.Errorf("node %s: not a child of its Parent %s", n.Key, n.Parent.name())
+		}
+	}
+	return nil
+}
+
+// splice replaces n with c in n's parent and fixes the sizes above it. It
+// then clears n's links, value and metadata, so that a caller still holding
+// n does not keep them reachable.
+func (n *BasicBST) splice(c *BasicBST) {
+	p := n.Parent
+	p.Child[n.which()] = c
+	if c != nil {
+		c.Parent = p
+	}
+	n.info.invalidate()
+	n.Value, n.Meta, n.agg = nil, nil, nil
+	n.Parent, n.Child = nil, [2]*BasicBST{}
+	p.fixUp()
+}
+
+// rank returns the number of keys less than k, or not greater than k if incl.
+func (n *BasicBST) rank(k KeyType, incl bool) int {
+	switch {
+	case n == nil:
+		return 0
+	case n.IsSentinel() || n.less(k, n.Key) || (!incl && !n.less(n.Key, k)):
+		return n.Child[lo].rank(k, incl)
+	default:
+		return n.Child[lo].count() + 1 + n.Child[hi].rank(k, incl)
+	}
+}
+
+// Rank returns the number of keys in the tree strictly less than k.
+func (n *BasicBST) Rank(k KeyType) int {
+	return n.rank(k, false)
+}
+
+// CountRange returns the number of keys in the closed range [from, to],
+// i.e. both ends are inclusive. It uses the subtree sizes and never visits
+// the nodes inside the range.
+func (n *BasicBST) CountRange(from, to KeyType) int {
+	if to.Less(from) {
+		return 0
+	}
+	return n.rank(to, true) - n.rank(from, false)
+}
+
+// buildBasic returns a perfectly balanced subtree holding the sorted pairs.
+func buildBasic(pairs []Pair, parent *BasicBST) *BasicBST {
+	if len(pairs) == 0 {
+		return nil
+	}
+	parent.info.invalidate()
+	m := len(pairs) / 2
+	n := &BasicBST{
+		Key:    pairs[m].Key,
+		Value:  pairs[m].Value,
+		Parent: parent,
+		id:     parent.info.nextID(),
+		info:   parent.info,
+	}
+	n.Child[lo] = buildBasic(pairs[:m], n)
+	n.Child[hi] = buildBasic(pairs[m+1:], n)
+	n.updateSize()
+	return n
+}
+
+// Rebalance rebuilds the tree below the sentinel into perfect balance,
+// keeping all of its key, value pairs with their versions and metadata.
+// When called on an internal node it rebuilds that node's subtree, which
+// replaces n in the tree. The rebuilt nodes are new; RebalanceNodes keeps
+// the old ones.
+func (n *BasicBST) Rebalance() {
+	if n == nil {
+		return
+	}
+	nodes := make([]*BasicBST, 0, n.Len())
+	n.Visit(func(n *BasicBST) error {
+		nodes = append(nodes, n)
+		return nil
+	})
+	if n.IsSentinel() {
+		n.Child[lo] = cloneBasic(nodes, n)
+		return
+	}
+	p, w := n.Parent, n.which()
+	p.Child[w] = cloneBasic(nodes, p)
+}
+
+// cloneBasic returns a perfectly balanced subtree, hung from parent, of
+// copies of the sorted nodes, keeping their keys, values, versions and
+// metadata. Each copy gets its own Meta map.
+func cloneBasic(nodes []*BasicBST, parent *BasicBST) *BasicBST {
+	if len(nodes) == 0 {
+		return nil
+	}
+	parent.info.invalidate()
+	m := len(nodes) / 2
+	n := &BasicBST{
+		Key:    nodes[m].Key,
+		Value:  nodes[m].Value,
+		Parent: parent,
+		ver:    nodes[m].ver,
+		id:     parent.info.nextID(),
+		info:   parent.info,
+	}
+	for name, v := range nodes[m].Meta {
+		n.SetMeta(name, v)
+	}
+	n.Child[lo] = cloneBasic(nodes[:m], n)
+	n.Child[hi] = cloneBasic(nodes[m+1:], n)
+	n.updateSize()
+	return n
+}
+
+// Filter returns a new balanced tree holding the pairs for which pred is true,
+// leaving n unchanged. The new tree starts at n's version and its nodes keep
+// the versions of the ones they were copied from.
+func (n *BasicBST) Filter(pred func(k KeyType, v interface{}) bool) *BasicBST {
+	var nodes []*BasicBST
+	n.Visit(func(n *BasicBST) error {
+		if pred(n.Key, n.Value) {
+			nodes = append(nodes, n)
+		}
+		return nil
+	})
+	t := NewBasic()
+	t.info.ver = n.Version()
+	t.Child[lo] = cloneBasic(nodes, t)
+	return t
+}
+
+// Partition returns two new balanced trees, holding the pairs for which pred
+// is true and false respectively, leaving n unchanged. Versions carry over as
+// in Filter.
+func (n *BasicBST) Partition(pred func(k KeyType, v interface{}) bool) (matching, rest *BasicBST) {
+	var yes, no []*BasicBST
+	n.Visit(func(n *BasicBST) error {
+		if pred(n.Key, n.Value) {
+			yes = append(yes, n)
+		} else {
+			no = append(no, n)
+		}
+		return nil
+	})
+	matching, rest = NewBasic(), NewBasic()
+	matching.info.ver, rest.info.ver = n.Version(), n.Version()
+	matching.Child[lo] = cloneBasic(yes, matching)
+	rest.Child[lo] = cloneBasic(no, rest)
+	return matching, rest
+}
+
+// Select returns the node holding the key of rank i, i.e. the i-th node
+// from low to high counting from 0, or nil if there is none.
+func (n *BasicBST) Select(i int) *BasicBST {
+	switch {
+	case n == nil:
+		return nil
+	case n.IsSentinel():
+		return n.Child[lo].Select(i)
+	}
+	switch r := n.Child[lo].count(); {
+	case i < r:
+		return n.Child[lo].Select(i)
+	case i == r:
+		return n
+	default:
+		return n.Child[hi].Select(i - r - 1)
+	}
+}
+
+// PageVisit visits up to limit nodes in tree order, starting with the node
+// of rank offset. The skipped nodes are passed over using the subtree sizes
+// and are never visited.
+func (n *BasicBST) PageVisit(offset, limit int, f func(n *BasicBST) error) error {
+	if offset < 0 {
+		offset = 0
+	}
+	for cur := n.Select(offset); cur != nil && limit > 0; cur = cur.Next() {
+		if err := f(cur); err != nil {
+			return err
+		}
+		limit--
+	}
+	return nil
+}
+
+// rotate moves n down to side d, lifting its child on the opposite side into
+// its place, and returns the lifted child.
+func (n *BasicBST) rotate(d int) *BasicBST {
+	r := opposite(d)
+	p, w, c := n.Parent, n.which(), n.Child[r]
+	n.Child[r] = c.Child[d]
+	if n.Child[r] != nil {
+		n.Child[r].Parent = n
+	}
+	c.Child[d] = n
+	n.Parent = c
+	c.Parent = p
+	p.Child[w] = c
+	n.updateSize()
+	c.updateSize()
+	n.info.rotated()
+	return c
+}
+
+// RotateLeft lifts n's hi child into n's place, moving n down to its lo side,
+// and returns the new subtree root. Ordering is preserved. It returns n
+// unchanged when n is the sentinel or has no hi child.
+func (n *BasicBST) RotateLeft() *BasicBST {
+	if n == nil || n.IsSentinel() || n.Child[hi] == nil {
+		return n
+	}
+	return n.rotate(lo)
+}
+
+// RotateRight lifts n's lo child into n's place, moving n down to its hi
+// side, and returns the new subtree root. Ordering is preserved. It returns
+// n unchanged when n is the sentinel or has no lo child.
+func (n *BasicBST) RotateRight() *BasicBST {
+	if n == nil || n.IsSentinel() || n.Child[lo] == nil {
+		return n
+	}
+	return n.rotate(hi)
+}
+
+// Diff compares the tree with other by merging their in-order sequences. It
+// returns the keys only in n, the keys only in other, and the keys in both
+// whose values differ according to valueEq.
+func (n *BasicBST) Diff(other *BasicBST, valueEq func(a, b interface{}) bool) (removed, added, changed []KeyType) {
+	a, b := n.Select(0), other.Select(0)
+	for a != nil && b != nil {
+		switch {
+		case a.Key.Less(b.Key):
+			removed = append(removed, a.Key)
+			a = a.Next()
+		case b.Key.Less(a.Key):
+			added = append(added, b.Key)
+			b = b.Next()
+		default:
+			if !valueEq(a.Value, b.Value) {
+				changed = append(changed, a.Key)
+			}
+			a, b = a.Next(), b.Next()
+		}
+	}
+	for ; a != nil; a = a.Next() {
+		removed = append(removed, a.Key)
+	}
+	for ; b != nil; b = b.Next() {
+		added = append(added, b.Key)
+	}
+	return removed, added, changed
+}
+
+// relink rearranges the sorted nodes into a perfectly balanced subtree under
+// parent and returns its root.
+func relink(nodes []*BasicBST, parent *BasicBST) *BasicBST {
+	if len(nodes) == 0 {
+		return nil
+	}
+	m := len(nodes) / 2
+	n := nodes[m]
+	n.Parent = parent
+	n.Child[lo] = relink(nodes[:m], n)
+	n.Child[hi] = relink(nodes[m+1:], n)
+	n.updateSize()
+	return n
+}
+
+// RebalanceNodes is like Rebalance, but rearranges the existing nodes rather
+// than allocating new ones, so pointers to them stay valid and keep their
+// keys, values and IDs.
+func (n *BasicBST) RebalanceNodes() {
+	if n == nil {
+		return
+	}
+	nodes := make([]*BasicBST, 0, n.Len())
+	n.Visit(func(n *BasicBST) error {
+		nodes = append(nodes, n)
+		return nil
+	})
+	if n.IsSentinel() {
+		n.Child[lo] = relink(nodes, n)
+		return
+	}
+	p, w := n.Parent, n.which()
+	p.Child[w] = relink(nodes, p)
+}
+
+// top returns the sentinel of n's tree, or nil if n is no longer in a tree,
+// as after Delete.
+func (n *BasicBST) top() *BasicBST {
+	for n != nil && !n.IsSentinel() {
+		n = n.Parent
+	}
+	return n
+}
+
+// IndexOf returns n's rank in its tree, found in O(height) by walking up the
+// Parent links and adding up the sizes of the subtrees to its left. It
+// returns -1 for the sentinel and for nodes no longer linked into a tree.
+func (n *BasicBST) IndexOf() int {
+	if n == nil || n.IsSentinel() {
+		return -1
+	}
+	i := n.Child[lo].count()
+	for cur := n; !cur.IsSentinel(); cur = cur.Parent {
+		switch cur.which() {
+		case hi:
+			i += cur.Parent.Child[lo].count() + 1
+		case -1:
+			return -1
+		}
+	}
+	return i
+}
+
+// Advance returns the node k places after n in tree order, or before it if k
+// is negative, or nil if there is none. It jumps using the subtree sizes
+// rather than stepping through the nodes in between.
+func (n *BasicBST) Advance(k int) *BasicBST {
+	i := n.IndexOf()
+	if i < 0 {
+		return nil
+	}
+	return n.top().Select(i + k)
+}
+
+// bound returns the lowest node whose key is not less than k, or greater
+// than k if strict.
+func (n *BasicBST) bound(k KeyType, strict bool) *BasicBST {
+	switch {
+	case n == nil:
+		return nil
+	case n.IsSentinel():
+		return n.Child[lo].bound(k, strict)
+	case n.Key.Less(k) || (strict && !k.Less(n.Key)):
+		return n.Child[hi].bound(k, strict)
+	}
+	if b := n.Child[lo].bound(k, strict); b != nil {
+		return b
+	}
+	return n
+}
+
+// LowerBound returns the first node whose key is not less than k, or nil.
+// Scanning can continue from it with Next.
+func (n *BasicBST) LowerBound(k KeyType) *BasicBST {
+	return n.bound(k, false)
+}
+
+// UpperBound returns the first node whose key is greater than k, or nil.
+// Scanning can continue from it with Next.
+func (n *BasicBST) UpperBound(k KeyType) *BasicBST {
+	return n.bound(k, true)
+}
+
+// ErrTooTall is returned by InsertGuarded when an insert would make the tree
+// too tall.
+var ErrTooTall = errors.New("tree too tall")
+
+// ErrDetached is returned when inserting through a node that is no longer in
+// a tree.
+var ErrDetached = errors.New("node not in a tree")
+
+// InsertGuarded inserts a key, value pair into n's tree like Insert, unless
+// the new node would make the tree taller than maxHeight, in which case it
+// returns an error wrapping ErrTooTall and leaves the tree unchanged.
+func (n *BasicBST) InsertGuarded(k KeyType, v interface{}, maxHeight int) error {
+	t := n.top()
+	if t == nil {
+		return fmt.Errorf("key %s: %w", k, ErrDetached)
+	}
+	depth := 0
+	for cur := t.Child[lo]; cur != nil; depth++ {
+		switch {
+		case k.Less(cur.Key):
+			cur = cur.Child[lo]
+		case cur.Key.Less(k):
+			cur = cur.Child[hi]
+		default:
+			cur.Value = cur.info.merge(cur.Value, v)
+			cur.ver = cur.info.bump()
+			cur.fixUp()
+			return nil
+		}
+	}
+	if depth > maxHeight {
+		return fmt.Errorf("key %s at depth %d: %w", k, depth, ErrTooTall)
+	}
+	t.Insert(k, v)
+	return nil
+}
+
+// Floor returns the last node whose key is not greater than k, or nil.
+func (n *BasicBST) Floor(k KeyType) *BasicBST {
+	switch {
+	case n == nil:
+		return nil
+	case n.IsSentinel() || k.Less(n.Key):
+		return n.Child[lo].Floor(k)
+	}
+	if f := n.Child[hi].Floor(k); f != nil {
+		return f
+	}
+	return n
+}
+
+// Ceil returns the first node whose key is not less than k, or nil.
+func (n *BasicBST) Ceil(k KeyType) *BasicBST {
+	return n.LowerBound(k)
+}
+
+// FindClosest returns the node whose key is nearest to k by dist, choosing
+// between Floor(k) and Ceil(k) and preferring the lower key on a tie. It
+// returns nil for an empty tree.
+func (n *BasicBST) FindClosest(k KeyType, dist func(a, b KeyType) int) *BasicBST {
+	f, c := n.Floor(k), n.Ceil(k)
+	switch {
+	case f == nil:
+		return c
+	case c == nil:
+		return f
+	case dist(k, c.Key) < dist(k, f.Key):
+		return c
+	default:
+		return f
+	}
+}
+
+// NearestK returns up to count nodes whose keys are nearest to k by dist, in
+// ascending distance, preferring the lower key on a tie. It expands outward
+// from Floor(k) and the node after it using Prev and Next, so fewer than
+// count nodes are returned only if the tree holds fewer.
+func (n *BasicBST) NearestK(k KeyType, count int, dist func(a, b KeyType) int) []*BasicBST {
+	var near []*BasicBST
+	f, c := n.Floor(k), n.UpperBound(k)
+	for len(near) < count && (f != nil || c != nil) {
+		if c == nil || f != nil && dist(k, f.Key) <= dist(k, c.Key) {
+			near = append(near, f)
+			f = f.Prev()
+		} else {
+			near = append(near, c)
+			c = c.Next()
+		}
+	}
+	return near
+}
+
+// PersistentInsert returns a new tree holding n's pairs plus (k, v), leaving
+// n's tree unchanged. Only the nodes on the path to k are copied; the rest
+// are shared with n's tree and keep their Parent links into it, so both trees
+// must be treated as immutable, and navigated with Get, Visit and the like
+// rather than Next, Prev or Delete. A key already present is resolved by
+// the tree's TiePolicy, as in Insert. On a node no longer in a tree it
+// returns nil.
+func (n *BasicBST) PersistentInsert(k KeyType, v interface{}) *BasicBST {
+	t := n.top()
+	if t == nil {
+		return nil
+	}
+	s := &BasicBST{info: t.info}
+	s.Parent = s
+	s.Child[lo] = t.Child[lo].pinsert(k, v, s)
+	return s
+}
+
+// pinsert returns a copy of n's subtree under parent with (k, v) inserted,
+// copying only the path down to k.
+func (n *BasicBST) pinsert(k KeyType, v interface{}, parent *BasicBST) *BasicBST {
+	if n == nil {
+		c := &BasicBST{
+			Key:    k,
+			Value:  parent.info.initial(v),
+			Parent: parent,
+			ver:    parent.info.bump(),
+			id:     parent.info.nextID(),
+			info:   parent.info,
+		}
+		c.updateSize()
+		return c
+	}
+	c := new(BasicBST)
+	*c = *n
+	c.Parent = parent
+	c.Meta = nil // each version gets its own Meta map
+	for name, v := range n.Meta {
+		c.SetMeta(name, v)
+	}
+	switch {
+	case k.Less(n.Key):
+		c.Child[lo] = n.Child[lo].pinsert(k, v, c)
+	case n.Key.Less(k):
+		c.Child[hi] = n.Child[hi].pinsert(k, v, c)
+	default:
+		old := n.Value
+		if s, ok := old.([]interface{}); ok {
+			old = s[:len(s):len(s)] // so Append cannot write into n's array
+		}
+		c.Value = c.info.merge(old, v)
+		c.ver = c.info.bump()
+	}
+	c.updateSize()
+	return c
+}
+
+// DeleteRange removes every key in the closed range [from, to] and returns
+// how many were removed.
+func (n *BasicBST) DeleteRange(from, to KeyType) int {
+	var keys []KeyType
+	n.RangeVisit(from, to, func(n *BasicBST) error {
+		keys = append(keys, n.Key)
+		return nil
+	})
+	for _, k := range keys {
+		n.Get(k).Delete()
+	}
+	return len(keys)
+}
+
+// Trim removes every key outside the closed range [from, to] and returns
+// how many were removed.
+func (n *BasicBST) Trim(from, to KeyType) int {
+	var keys []KeyType
+	n.Visit(func(n *BasicBST) error {
+		if n.Key.Less(from) || to.Less(n.Key) {
+			keys = append(keys, n.Key)
+		}
+		return nil
+	})
+	for _, k := range keys {
+		n.Get(k).Delete()
+	}
+	return len(keys)
+}
+
+// VerifyAcyclic checks the links below n without recursing into a cycle. It
+// returns an error describing the first node reached twice, or whose Parent
+// does not point back to the node linking to it, or nil if there is none.
+func (n *BasicBST) VerifyAcyclic() error {
+	seen := map[*BasicBST]bool{n: true}
+	stack := []*BasicBST{n}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for d, c := range p.Child {
+			if c == nil || (d == hi && p.IsSentinel()) {
+				continue
+			}
+			if seen[c] {
+				return fmt.Errorf("node %s: reached twice, the links form a cycle", c.name())
+			}
+			if c.Parent != p {
+				return fmt.Errorf("node %s: Parent does not point back to %s", c.Key, p.name())
+			}
+			seen[c] = true
+			stack = append(stack, c)
+		}
+	}
+	return nil
+}
+
+// name returns n's key as a string, or "sentinel".
+func (n *BasicBST) name() string {
+	if n.IsSentinel() {
+		return "sentinel"
+	}
+	return n.Key.String()
+}
+
+// SubtreeAggregate returns the aggregate of the values of n's subtree whose
+// keys lie in the closed range [from, to], in O(height). It returns nil for a
+// tree not made by NewBasicAggregate.
+func (n *BasicBST) SubtreeAggregate(from, to KeyType) interface{} {
+	t := n.info
+	if t == nil || t.combine == nil {
+		return nil
+	}
+	if n.IsSentinel() {
+		n = n.Child[lo]
+	}
+	if to.Less(from) {
+		return t.zero
+	}
+	return t.rangeAggregate(n, from, to)
+}
+
+// ToAVL returns a balanced AVL holding the tree's pairs.
+func (n *BasicBST) ToAVL() *AVL {
+	return NewAVLFromSorted(n.ToSlice())
+}
@@ -2,10 +2,16 @@ package bst
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/rand"
 	"os"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type iKey int
@@ -178,3 +184,1628 @@ func TestBasicInsertAndVisit(t *testing.T) {
 		}
 	})
 }
+
+// newSeq returns a BasicBST holding keys 0..size-1 inserted in random order,
+// each with value -key.
+func newSeq(size int) *BasicBST {
+	s := NewBasic()
+	for _, k := range rand.Perm(size) {
+		s.Insert(iKey(k), -k)
+	}
+	return s
+}
+
+func TestCountRange(t *testing.T) {
+	s := newSeq(100)
+	cases := []struct {
+		from, to iKey
+		want     int
+	}{
+		{from: 30, to: 40, want: 11},
+		{from: 40, to: 30, want: 0},
+		{from: 200, to: 300, want: 0},
+		{from: -10, to: 0, want: 1},
+		{from: 0, to: 99, want: 100},
+	}
+	for _, c := range cases {
+		if got := s.CountRange(c.from, c.to); got != c.want {
+			t.Errorf("bad CountRange(%d, %d): got %d, want %d", c.from, c.to, got, c.want)
+		}
+	}
+	for _, k := range [...]int{35, 3, 70} {
+		s.Get(iKey(k)).Delete()
+	}
+	if got, want := s.CountRange(iKey(30), iKey(40)), 10; got != want {
+		t.Errorf("bad CountRange after delete: got %d, want %d", got, want)
+	}
+	if got, want := s.Rank(iKey(50)), 48; got != want {
+		t.Errorf("bad Rank(50): got %d, want %d", got, want)
+	}
+	// On a balanced tree CountRange compares along two root paths, so its
+	// work is bounded by the height and not by the width of the range.
+	b := newSeq(1 << 12)
+	b.Rebalance()
+	before := b.Stats().Comparisons
+	if got, want := b.CountRange(iKey(10), iKey(4000)), 3991; got != want {
+		t.Errorf("bad wide CountRange: got %d, want %d", got, want)
+	}
+	if got, max := b.Stats().Comparisons-before, uint64(4*(b.Height()+1)); got > max {
+		t.Errorf("bad CountRange comparisons: got %d, want at most %d", got, max)
+	}
+}
+
+// shuffledPairs returns the pairs {k, -k} for k in 0..size-1 in random order.
+func shuffledPairs(size int) []Pair {
+	pairs := make([]Pair, 0, size)
+	for _, k := range rand.Perm(size) {
+		pairs = append(pairs, Pair{Key: iKey(k), Value: -k})
+	}
+	return pairs
+}
+
+func TestInsertAll(t *testing.T) {
+	const size = 10000
+	s := NewBasic()
+	s.InsertAll(shuffledPairs(size))
+	if got := s.Len(); got != size {
+		t.Errorf("bad size: got %d, want %d", got, size)
+	}
+	want := 0
+	s.Visit(func(n *BasicBST) error {
+		if k := int(n.Key.(iKey)); k != want {
+			t.Errorf("bad key: got %d, want %d", k, want)
+		}
+		if v := n.Value.(int); v != -want {
+			t.Errorf("bad value at %d: got %d", want, v)
+		}
+		want++
+		return nil
+	})
+	for n := range s.Check(context.Background()) {
+		t.Errorf("violating node: %+v", *n)
+	}
+}
+
+func BenchmarkInsertAll(b *testing.B) {
+	pairs := shuffledPairs(10000)
+	b.Run("InsertAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewBasic().InsertAll(pairs)
+		}
+	})
+	b.Run("Loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			s := NewBasic()
+			for _, p := range pairs {
+				s.Insert(p.Key, p.Value)
+			}
+		}
+	})
+}
+
+func TestAllKeys(t *testing.T) {
+	keys := newSeq(100).AllKeys()
+	if len(keys) != 100 || cap(keys) != 100 {
+		t.Errorf("bad length: got %d (cap %d), want 100", len(keys), cap(keys))
+	}
+	for i, k := range keys {
+		if got := int(k.(iKey)); got != i {
+			t.Errorf("bad key at %d: got %d", i, got)
+		}
+	}
+	if keys := NewBasic().AllKeys(); len(keys) != 0 {
+		t.Errorf("unexpected keys from empty tree: %v", keys)
+	}
+}
+
+func TestToSlice(t *testing.T) {
+	s := newSeq(100)
+	pairs := s.ToSlice()
+	if len(pairs) != s.Len() {
+		t.Errorf("bad length: got %d, want %d", len(pairs), s.Len())
+	}
+	for i, p := range pairs {
+		if k := int(p.Key.(iKey)); k != i {
+			t.Errorf("bad key at %d: got %d", i, k)
+		}
+		if v := p.Value.(int); v != -i {
+			t.Errorf("bad value at %d: got %d", i, v)
+		}
+	}
+	if pairs := NewBasic().ToSlice(); len(pairs) != 0 {
+		t.Errorf("unexpected pairs from empty tree: %v", pairs)
+	}
+}
+
+func TestGetZeroKey(t *testing.T) {
+	s := NewBasic()
+	s.Insert(iKey(7), -7)
+	if n := s.Get(iKey(0)); n != nil {
+		t.Errorf("unexpected match for absent zero key: %+v", *n)
+	}
+	s.Insert(iKey(0), 0)
+	n := s.Get(iKey(0))
+	switch {
+	case n == nil:
+		t.Errorf("missing zero key")
+	case n.IsSentinel():
+		t.Errorf("Get returned the sentinel")
+	case n.Key.(iKey) != 0:
+		t.Errorf("bad key: got %v, want 0", n.Key)
+	}
+	if n := NewBasic().Get(iKey(0)); n != nil {
+		t.Errorf("unexpected match in empty tree: %+v", *n)
+	}
+}
+
+func TestString(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{2, 1, 3} {
+		s.Insert(iKey(k), -k)
+	}
+	want := "    3(0)\n2(1)\n    1(0)\n"
+	if got := s.String(); got != want {
+		t.Errorf("bad String:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if got := NewBasic().String(); got != "" {
+		t.Errorf("bad empty String: %q", got)
+	}
+	d := NewBasic()
+	for k := 0; k < 4; k++ {
+		d.Insert(iKey(k), -k)
+	}
+	want = "            3(0)\n        2(1)\n    1(2)\n0(3)\n"
+	if got := d.String(); got != want {
+		t.Errorf("bad degenerate String:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	const size = 1000
+	s := NewBasic()
+	for k := 0; k < size; k++ {
+		s.Insert(iKey(k), -k)
+	}
+	if got, want := s.Height(), size-1; got != want {
+		t.Errorf("bad height before Rebalance: got %d, want %d", got, want)
+	}
+	before := s.ToSlice()
+	sentinel := s
+	s.Rebalance()
+	if s != sentinel || !s.IsSentinel() {
+		t.Errorf("Rebalance replaced the sentinel")
+	}
+	if got, want := s.Height(), 9; got != want {
+		t.Errorf("bad height after Rebalance: got %d, want %d", got, want)
+	}
+	after := s.ToSlice()
+	if len(after) != len(before) {
+		t.Fatalf("bad length after Rebalance: got %d, want %d", len(after), len(before))
+	}
+	for i := range before {
+		if !after[i].Key.Equal(before[i].Key) || after[i].Value != before[i].Value {
+			t.Errorf("bad pair at %d: got %+v, want %+v", i, after[i], before[i])
+		}
+	}
+	for n := range s.Check(context.Background()) {
+		t.Errorf("violating node: %+v", *n)
+	}
+}
+
+func TestVisitContext(t *testing.T) {
+	s := newSeq(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	visited := 0
+	err := s.VisitContext(ctx, func(n *BasicBST) error {
+		visited++
+		if visited == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("bad error: got %v, want %v", err, context.Canceled)
+	}
+	if visited != 5 {
+		t.Errorf("bad visit count after cancel: got %d, want 5", visited)
+	}
+	visited = 0
+	if err := s.VisitContext(context.Background(), func(n *BasicBST) error {
+		visited++
+		return nil
+	}); err != nil || visited != 100 {
+		t.Errorf("bad full visit: got %d nodes, err %v", visited, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := newSeq(100)
+	if err := s.Validate(); err != nil {
+		t.Errorf("unexpected error on valid tree: %v", err)
+	}
+	if err := NewBasic().Validate(); err != nil {
+		t.Errorf("unexpected error on empty tree: %v", err)
+	}
+	s.Rebalance()
+	s.Child[lo].Key = iKey(1000)
+	err := s.Validate()
+	if err == nil {
+		t.Fatalf("missing error on corrupted tree")
+	}
+	t.Logf("corruption reported: %v", err)
+	if !strings.Contains(err.Error(), "1000") {
+		t.Errorf("error does not name the offending key: %v", err)
+	}
+}
+
+func TestMapValues(t *testing.T) {
+	s := newSeq(100)
+	before := s.String()
+	s.MapValues(func(k KeyType, v interface{}) interface{} {
+		return -v.(int)
+	})
+	if after := s.String(); after != before {
+		t.Errorf("MapValues changed the tree shape")
+	}
+	for i, p := range s.ToSlice() {
+		if k := int(p.Key.(iKey)); k != i {
+			t.Errorf("bad key at %d: got %d", i, k)
+		}
+		if v := p.Value.(int); v != i {
+			t.Errorf("bad value at %d: got %d, want %d", i, v, i)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	s := newSeq(100)
+	before := s.String()
+	even := s.Filter(func(k KeyType, v interface{}) bool {
+		return k.(iKey)%2 == 0
+	})
+	if got := even.Len(); got != 50 {
+		t.Errorf("bad filtered Len: got %d, want 50", got)
+	}
+	if err := even.Validate(); err != nil {
+		t.Errorf("filtered tree is invalid: %v", err)
+	}
+	for i, p := range even.ToSlice() {
+		if k := int(p.Key.(iKey)); k != 2*i {
+			t.Errorf("bad key at %d: got %d, want %d", i, k, 2*i)
+		}
+	}
+	if s.Len() != 100 || s.String() != before {
+		t.Errorf("Filter changed the original tree")
+	}
+}
+
+func TestTraverse(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{4, 2, 6, 1, 3, 5, 7} {
+		s.Insert(iKey(k), -k)
+	}
+	for _, c := range []struct {
+		order Order
+		want  string
+	}{
+		{PreOrder, "[4 2 1 3 6 5 7]"},
+		{InOrder, "[1 2 3 4 5 6 7]"},
+		{PostOrder, "[1 3 2 5 7 6 4]"},
+	} {
+		var got []int
+		err := s.Traverse(c.order, func(n *BasicBST) error {
+			got = append(got, int(n.Key.(iKey)))
+			return nil
+		})
+		if err != nil || fmt.Sprint(got) != c.want {
+			t.Errorf("bad order %d: got %v (%v), want %s", c.order, got, err, c.want)
+		}
+		stop := errors.New("stop")
+		calls := 0
+		err = s.Traverse(c.order, func(n *BasicBST) error {
+			if calls++; calls == 3 {
+				return stop
+			}
+			return nil
+		})
+		if err != stop || calls != 3 {
+			t.Errorf("bad early exit in order %d: got %v after %d calls", c.order, err, calls)
+		}
+	}
+	if err := s.Traverse(Order(9), func(*BasicBST) error { return nil }); err == nil {
+		t.Errorf("missing error for a bad order")
+	}
+	if err := NewBasic().Traverse(PostOrder, func(*BasicBST) error { return errors.New("called") }); err != nil {
+		t.Errorf("bad empty traversal: %v", err)
+	}
+}
+
+func TestVisitWithNeighbors(t *testing.T) {
+	name := func(n *BasicBST) string {
+		if n == nil {
+			return "nil"
+		}
+		return n.Key.String()
+	}
+	var got []string
+	newSeq(5).VisitWithNeighbors(func(prev, cur, next *BasicBST) error {
+		got = append(got, name(prev)+" "+name(cur)+" "+name(next))
+		return nil
+	})
+	want := []string{"nil 0 1", "0 1 2", "1 2 3", "2 3 4", "3 4 nil"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("bad triples: got %q, want %q", got, want)
+	}
+	calls := 0
+	NewBasic().VisitWithNeighbors(func(prev, cur, next *BasicBST) error {
+		calls++
+		return nil
+	})
+	if calls != 0 {
+		t.Errorf("bad calls on empty tree: got %d, want 0", calls)
+	}
+}
+
+func TestTakeWhile(t *testing.T) {
+	s := newSeq(100)
+	var got []int
+	calls := 0
+	s.TakeWhile(func(n *BasicBST) bool {
+		calls++
+		if !n.Key.Less(iKey(10)) {
+			return false
+		}
+		got = append(got, int(n.Key.(iKey)))
+		return true
+	})
+	if len(got) != 10 || calls != 11 {
+		t.Fatalf("bad visits: got %d keys in %d calls, want 10 in 11", len(got), calls)
+	}
+	for i, k := range got {
+		if k != i {
+			t.Errorf("bad key at %d: got %d", i, k)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	s := newSeq(100)
+	before := s.String()
+	even, odd := s.Partition(func(k KeyType, v interface{}) bool {
+		return k.(iKey)%2 == 0
+	})
+	for _, c := range []struct {
+		name  string
+		tree  *BasicBST
+		first int
+	}{{"even", even, 0}, {"odd", odd, 1}} {
+		if got := c.tree.Len(); got != 50 {
+			t.Errorf("bad %s Len: got %d, want 50", c.name, got)
+		}
+		for n := range c.tree.Check(context.Background()) {
+			t.Errorf("violating %s node: %+v", c.name, *n)
+		}
+		for i, p := range c.tree.ToSlice() {
+			if k, want := int(p.Key.(iKey)), 2*i+c.first; k != want {
+				t.Errorf("bad %s key at %d: got %d, want %d", c.name, i, k, want)
+			}
+		}
+	}
+	if s.Len() != 100 || s.String() != before {
+		t.Errorf("Partition changed the original tree")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if v, ok := NewBasic().Lookup(iKey(0)); ok || v != nil {
+		t.Errorf("bad Lookup on empty tree: got %v, %v", v, ok)
+	}
+	s := newSeq(10)
+	s.Insert(iKey(20), nil)
+	for _, c := range []struct {
+		k  iKey
+		v  interface{}
+		ok bool
+	}{
+		{k: 0, v: 0, ok: true},
+		{k: 7, v: -7, ok: true},
+		{k: 20, v: nil, ok: true},
+		{k: 10, v: nil, ok: false},
+	} {
+		if v, ok := s.Lookup(c.k); v != c.v || ok != c.ok {
+			t.Errorf("bad Lookup(%d): got %v, %v, want %v, %v", c.k, v, ok, c.v, c.ok)
+		}
+	}
+}
+
+func TestNextPrevDetached(t *testing.T) {
+	s := NewBasic()
+	if n := s.Next(); n != nil {
+		t.Errorf("bad Next on empty sentinel: %+v", *n)
+	}
+	for _, k := range [...]int{4, 2, 6, 1, 3, 5, 7} {
+		s.Insert(iKey(k), -k)
+	}
+	if n := s.Next(); n != nil {
+		t.Errorf("bad Next on sentinel: %+v", *n)
+	}
+	if n := s.Prev(); n != nil {
+		t.Errorf("bad Prev on sentinel: %+v", *n)
+	}
+	for _, k := range [...]int{1, 2} {
+		d := s.Get(iKey(k))
+		d.Delete()
+		if n := d.Next(); n != nil {
+			t.Errorf("bad Next on deleted %d: %+v", k, *n)
+		}
+		if n := d.Prev(); n != nil {
+			t.Errorf("bad Prev on deleted %d: %+v", k, *n)
+		}
+	}
+	if n := s.Get(iKey(3)).Next(); n == nil || n.Key.(iKey) != 4 {
+		t.Errorf("bad Next(3) after delete: %v", n)
+	}
+}
+
+func TestPageVisit(t *testing.T) {
+	s := newSeq(100)
+	var got []int
+	s.PageVisit(20, 10, func(n *BasicBST) error {
+		got = append(got, int(n.Key.(iKey)))
+		return nil
+	})
+	if len(got) != 10 {
+		t.Fatalf("bad page length: got %d, want 10", len(got))
+	}
+	for i, k := range got {
+		if k != 20+i {
+			t.Errorf("bad key at %d: got %d, want %d", i, k, 20+i)
+		}
+	}
+	visited := 0
+	s.PageVisit(95, 10, func(n *BasicBST) error {
+		visited++
+		return nil
+	})
+	if visited != 5 {
+		t.Errorf("bad last page length: got %d, want 5", visited)
+	}
+	for i := 0; i < 100; i++ {
+		if k := int(s.Select(i).Key.(iKey)); k != i {
+			t.Errorf("bad Select(%d): got %d", i, k)
+		}
+	}
+	if n := s.Select(100); n != nil {
+		t.Errorf("bad Select(100): %+v", *n)
+	}
+}
+
+func TestSubtreeIteration(t *testing.T) {
+	s := newSeq(15)
+	s.Rebalance()
+	sub := s.Child[lo].Child[lo] // holds keys 0..6
+	want := 0
+	for k := range sub.Keys(context.Background()) {
+		if got := int(k.(iKey)); got != want {
+			t.Errorf("bad subtree key: got %d, want %d", got, want)
+		}
+		want++
+	}
+	if want != sub.Len() || want != 7 {
+		t.Errorf("bad subtree key count: got %d, want 7", want)
+	}
+	var got []int
+	sub.RangeVisit(iKey(4), iKey(10), func(n *BasicBST) error {
+		got = append(got, int(n.Key.(iKey)))
+		return nil
+	})
+	if len(got) != 3 || got[0] != 4 || got[2] != 6 {
+		t.Errorf("bad subtree RangeVisit: got %v, want [4 5 6]", got)
+	}
+	got = got[:0]
+	s.RangeVisit(iKey(4), iKey(10), func(n *BasicBST) error {
+		got = append(got, int(n.Key.(iKey)))
+		return nil
+	})
+	if len(got) != 7 || got[0] != 4 || got[6] != 10 {
+		t.Errorf("bad RangeVisit: got %v, want 4..10", got)
+	}
+}
+
+func TestSizedClear(t *testing.T) {
+	s := NewBasicSized(50)
+	for round := 0; round < 2; round++ {
+		for _, k := range rand.Perm(100) {
+			s.Insert(iKey(k), -k)
+		}
+		if got := s.Len(); got != 100 {
+			t.Errorf("round %d: bad Len: got %d, want 100", round, got)
+		}
+		if err := s.Validate(); err != nil {
+			t.Errorf("round %d: invalid tree: %v", round, err)
+		}
+		s.Clear()
+		if got := s.Len(); got != 0 {
+			t.Errorf("round %d: bad Len after Clear: %d", round, got)
+		}
+	}
+}
+
+func BenchmarkSizedInsert(b *testing.B) {
+	const size = 10000
+	keys := rand.Perm(size)
+	b.Run("Plain", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s := NewBasic()
+			for _, k := range keys {
+				s.Insert(iKey(k), nil)
+			}
+		}
+	})
+	b.Run("Sized", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s := NewBasicSized(size)
+			for _, k := range keys {
+				s.Insert(iKey(k), nil)
+			}
+		}
+	})
+}
+
+func TestRotate(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{4, 2, 6, 1, 3, 5, 7} {
+		s.Insert(iKey(k), -k)
+	}
+	want := s.ToSlice()
+	check := func(t *testing.T) {
+		if err := s.Validate(); err != nil {
+			t.Errorf("invalid after rotation: %v", err)
+		}
+		got := s.ToSlice()
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("bad pair at %d: got %+v, want %+v", i, got[i], want[i])
+			}
+		}
+		if s.Child[lo].Parent != s {
+			t.Errorf("bad root link after rotation")
+		}
+	}
+	t.Run("Left", func(t *testing.T) {
+		r := s.Child[lo].RotateLeft()
+		if r.Key.(iKey) != 6 || s.Child[lo] != r {
+			t.Errorf("bad new root: %v", r.Key)
+		}
+		if r.Len() != 7 || r.Child[lo].Len() != 5 {
+			t.Errorf("bad sizes after rotation: %d, %d", r.Len(), r.Child[lo].Len())
+		}
+		check(t)
+	})
+	t.Run("Right", func(t *testing.T) {
+		r := s.Child[lo].RotateRight()
+		if r.Key.(iKey) != 4 || s.Child[lo] != r {
+			t.Errorf("bad new root: %v", r.Key)
+		}
+		r = s.Get(iKey(2)).RotateRight()
+		if r.Key.(iKey) != 1 || r.Parent.Key.(iKey) != 4 {
+			t.Errorf("bad inner rotation: %v", r.Key)
+		}
+		check(t)
+	})
+	if got := s.Stats().Rotations; got != 3 {
+		t.Errorf("bad rotation count: got %d, want 3", got)
+	}
+}
+
+func TestGetOrInsert(t *testing.T) {
+	s := newSeq(10)
+	n, created := s.GetOrInsert(iKey(20), -20)
+	if !created || n.Key.(iKey) != 20 || n.Value.(int) != -20 {
+		t.Errorf("bad created node: %+v, %v", *n, created)
+	}
+	if s.Get(iKey(20)) != n || s.Len() != 11 {
+		t.Errorf("inserted node not found in tree")
+	}
+	n, created = s.GetOrInsert(iKey(5), 100)
+	if created || n.Key.(iKey) != 5 || n.Value.(int) != -5 {
+		t.Errorf("bad existing node: %+v, %v", *n, created)
+	}
+	if s.Len() != 11 {
+		t.Errorf("bad Len: got %d, want 11", s.Len())
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	s := newSeq(10)
+	incr := func(old interface{}) interface{} {
+		return old.(int) + 1
+	}
+	if !s.Update(iKey(3), incr) {
+		t.Errorf("Update(3) reported a missing key")
+	}
+	if v, _ := s.Lookup(iKey(3)); v.(int) != -2 {
+		t.Errorf("bad value after Update: got %v, want -2", v)
+	}
+	if s.Update(iKey(30), incr) {
+		t.Errorf("Update(30) reported a present key")
+	}
+	if _, ok := s.Lookup(iKey(30)); ok || s.Len() != 10 {
+		t.Errorf("Update inserted a missing key")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a, b := NewBasic(), NewBasic()
+	for k := 0; k < 10; k++ {
+		a.Insert(iKey(k), -k)
+	}
+	for k := 5; k < 15; k++ {
+		v := -k
+		if k%3 == 0 {
+			v = k
+		}
+		b.Insert(iKey(k), v)
+	}
+	removed, added, changed := a.Diff(b, func(x, y interface{}) bool {
+		return x.(int) == y.(int)
+	})
+	ints := func(keys []KeyType) []int {
+		r := make([]int, len(keys))
+		for i, k := range keys {
+			r[i] = int(k.(iKey))
+		}
+		return r
+	}
+	for _, c := range []struct {
+		name string
+		got  []int
+		want []int
+	}{
+		{name: "removed", got: ints(removed), want: []int{0, 1, 2, 3, 4}},
+		{name: "added", got: ints(added), want: []int{10, 11, 12, 13, 14}},
+		{name: "changed", got: ints(changed), want: []int{6, 9}},
+	} {
+		if fmt.Sprint(c.got) != fmt.Sprint(c.want) {
+			t.Errorf("bad %s keys: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestRebalanceNodes(t *testing.T) {
+	s := NewBasic()
+	for k := 0; k < 1000; k++ {
+		s.Insert(iKey(k), -k)
+	}
+	held := s.Get(iKey(123))
+	s.RebalanceNodes()
+	if got, want := s.Height(), 9; got != want {
+		t.Errorf("bad height after RebalanceNodes: got %d, want %d", got, want)
+	}
+	if s.Get(iKey(123)) != held {
+		t.Errorf("held node is no longer in the tree")
+	}
+	if held.Key.(iKey) != 123 || held.Value.(int) != -123 {
+		t.Errorf("held node changed: %+v", *held)
+	}
+	if n := held.Next(); n == nil || n.Key.(iKey) != 124 {
+		t.Errorf("bad Next from held node")
+	}
+	if err := s.Validate(); err != nil || s.Len() != 1000 {
+		t.Errorf("bad tree after RebalanceNodes: %v, %d keys", err, s.Len())
+	}
+	s.Rebalance()
+	if n := s.Get(iKey(123)); n == held || n.ID() == held.ID() {
+		t.Errorf("Rebalance kept the held node rather than rebuilding")
+	}
+}
+
+func TestIndexOf(t *testing.T) {
+	s := newSeq(100)
+	for i := 0; i < 100; i++ {
+		if got := s.Get(iKey(i)).IndexOf(); got != i {
+			t.Errorf("bad IndexOf: got %d, want %d", got, i)
+		}
+	}
+	if got := s.IndexOf(); got != -1 {
+		t.Errorf("bad sentinel IndexOf: got %d, want -1", got)
+	}
+	n := s.Select(0)
+	n.Delete()
+	if got := n.IndexOf(); got != -1 {
+		t.Errorf("bad detached IndexOf: got %d, want -1", got)
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	s := newSeq(100)
+	n := s.Get(iKey(10))
+	for _, c := range []struct {
+		k, want int
+	}{
+		{k: 5, want: 15},
+		{k: -10, want: 0},
+		{k: 0, want: 10},
+		{k: 89, want: 99},
+	} {
+		if got := n.Advance(c.k); got == nil || int(got.Key.(iKey)) != c.want {
+			t.Errorf("bad Advance(%d) from 10: got %v, want %d", c.k, got, c.want)
+		}
+	}
+	for _, k := range [...]int{-11, 90} {
+		if got := n.Advance(k); got != nil {
+			t.Errorf("bad Advance(%d) from 10: got %v, want nil", k, got.Key)
+		}
+	}
+	if got := s.Advance(1); got != nil {
+		t.Errorf("bad Advance from sentinel: %v", got.Key)
+	}
+}
+
+func TestWalkDetailed(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{4, 2, 6, 3, 7} {
+		s.Insert(iKey(k), -k)
+	}
+	want := []struct{ key, depth, side int }{
+		{key: 2, depth: 1, side: lo},
+		{key: 3, depth: 2, side: hi},
+		{key: 4, depth: 0, side: -1},
+		{key: 6, depth: 1, side: hi},
+		{key: 7, depth: 2, side: hi},
+	}
+	i := 0
+	s.WalkDetailed(func(n *BasicBST, depth int, side int) error {
+		got := struct{ key, depth, side int }{int(n.Key.(iKey)), depth, side}
+		if i >= len(want) || got != want[i] {
+			t.Errorf("bad walk step %d: got %+v", i, got)
+		} else {
+			i++
+		}
+		return nil
+	})
+	if i != len(want) {
+		t.Errorf("bad walk length: got %d, want %d", i, len(want))
+	}
+	stop := errors.New("stop")
+	steps := 0
+	err := s.WalkDetailed(func(n *BasicBST, depth int, side int) error {
+		steps++
+		if n.Key.(iKey) == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || steps != 2 {
+		t.Errorf("bad early exit: err %v after %d steps", err, steps)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{5, 1, 7, 3} {
+		s.Insert(iKey(k), -k)
+	}
+	for _, c := range []struct {
+		name string
+		n    *BasicBST
+		want []int
+	}{
+		{name: "LowerBound(4)", n: s.LowerBound(iKey(4)), want: []int{5, 7}},
+		{name: "LowerBound(5)", n: s.LowerBound(iKey(5)), want: []int{5, 7}},
+		{name: "UpperBound(5)", n: s.UpperBound(iKey(5)), want: []int{7}},
+		{name: "UpperBound(0)", n: s.UpperBound(iKey(0)), want: []int{1, 3, 5, 7}},
+		{name: "LowerBound(8)", n: s.LowerBound(iKey(8)), want: nil},
+		{name: "UpperBound(7)", n: s.UpperBound(iKey(7)), want: nil},
+	} {
+		var got []int
+		for cur := c.n; cur != nil; cur = cur.Next() {
+			got = append(got, int(cur.Key.(iKey)))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("bad scan from %s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestInsertReturnsNode(t *testing.T) {
+	s := newSeq(10)
+	n := s.Insert(iKey(20), -20)
+	if n == nil || n.Key.(iKey) != 20 || n.Value.(int) != -20 {
+		t.Fatalf("bad node from Insert: %v", n)
+	}
+	if s.Get(iKey(20)) != n {
+		t.Errorf("Insert returned a node not in the tree")
+	}
+	again := s.Insert(iKey(20), 20)
+	if again != n || n.Value.(int) != 20 {
+		t.Errorf("repeated Insert returned a different node or kept the old value")
+	}
+}
+
+func TestInsertGuarded(t *testing.T) {
+	const maxHeight = 4
+	s := NewBasic()
+	for k := 0; k <= maxHeight; k++ {
+		if err := s.InsertGuarded(iKey(k), -k, maxHeight); err != nil {
+			t.Fatalf("unexpected error inserting %d: %v", k, err)
+		}
+	}
+	err := s.InsertGuarded(iKey(maxHeight+1), 0, maxHeight)
+	if !errors.Is(err, ErrTooTall) {
+		t.Errorf("bad error inserting past the limit: %v", err)
+	}
+	if s.Len() != maxHeight+1 || s.Height() != maxHeight {
+		t.Errorf("refused insert changed the tree")
+	}
+	if err := s.InsertGuarded(iKey(2), 2, maxHeight); err != nil {
+		t.Errorf("unexpected error updating a present key: %v", err)
+	}
+	s.Rebalance()
+	if err := s.InsertGuarded(iKey(maxHeight+1), 0, maxHeight); err != nil {
+		t.Errorf("unexpected error after Rebalance: %v", err)
+	}
+}
+
+func TestVisitLevelOrder(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{2, 1, 3} {
+		s.Insert(iKey(k), -k)
+	}
+	var levels [][]int
+	s.VisitLevelOrder(func(n *BasicBST, level int) error {
+		if level == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[level] = append(levels[level], int(n.Key.(iKey)))
+		return nil
+	})
+	if got, want := fmt.Sprint(levels), "[[2] [1 3]]"; got != want {
+		t.Errorf("bad levels: got %s, want %s", got, want)
+	}
+	visited := 0
+	stop := errors.New("stop")
+	if err := s.VisitLevelOrder(func(n *BasicBST, level int) error {
+		visited++
+		return stop
+	}); err != stop || visited != 1 {
+		t.Errorf("bad early exit: err %v after %d nodes", err, visited)
+	}
+}
+
+func TestSubtreeSize(t *testing.T) {
+	s := newSeq(100)
+	var nilNode *BasicBST
+	if got := nilNode.SubtreeSize(); got != 0 {
+		t.Errorf("bad nil SubtreeSize: %d", got)
+	}
+	for range nilNode.SubtreeKeys(context.Background()) {
+		t.Errorf("unexpected key from nil subtree")
+	}
+	for _, k := range [...]int{0, 25, 50, 99} {
+		n := s.Get(iKey(k))
+		visited := 0
+		n.Visit(func(*BasicBST) error {
+			visited++
+			return nil
+		})
+		if got := n.SubtreeSize(); got != visited {
+			t.Errorf("bad SubtreeSize at %d: got %d, want %d", k, got, visited)
+		}
+		streamed := 0
+		for range n.SubtreeKeys(context.Background()) {
+			streamed++
+		}
+		if streamed != visited {
+			t.Errorf("bad SubtreeKeys count at %d: got %d, want %d", k, streamed, visited)
+		}
+	}
+}
+
+// iDist is the distance between two iKeys.
+func iDist(a, b KeyType) int {
+	return iabs(int(a.(iKey)) - int(b.(iKey)))
+}
+
+func TestFindClosest(t *testing.T) {
+	if n := NewBasic().FindClosest(iKey(1), iDist); n != nil {
+		t.Errorf("unexpected match in empty tree: %v", n.Key)
+	}
+	s := NewBasic()
+	for _, k := range [...]int{20, 10, 30} {
+		s.Insert(iKey(k), -k)
+	}
+	for _, c := range []struct {
+		k, want int
+	}{
+		{k: 22, want: 20},
+		{k: 26, want: 30},
+		{k: 25, want: 20},
+		{k: 20, want: 20},
+		{k: 0, want: 10},
+		{k: 99, want: 30},
+	} {
+		if got := s.FindClosest(iKey(c.k), iDist); got == nil || int(got.Key.(iKey)) != c.want {
+			t.Errorf("bad FindClosest(%d): got %v, want %d", c.k, got, c.want)
+		}
+	}
+	if f := s.Floor(iKey(29)); f == nil || f.Key.(iKey) != 20 {
+		t.Errorf("bad Floor(29): %v", f)
+	}
+	if f := s.Floor(iKey(9)); f != nil {
+		t.Errorf("bad Floor(9): %v", f.Key)
+	}
+}
+
+func TestNearestK(t *testing.T) {
+	s := newSeq(100)
+	for _, c := range []struct {
+		k, count int
+		want     []int
+	}{
+		{k: 50, count: 3, want: []int{50, 49, 51}},
+		{k: 0, count: 3, want: []int{0, 1, 2}},
+		{k: 120, count: 2, want: []int{99, 98}},
+		{k: 10, count: 0, want: nil},
+	} {
+		var got []int
+		for _, n := range s.NearestK(iKey(c.k), c.count, iDist) {
+			got = append(got, int(n.Key.(iKey)))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("bad NearestK(%d, %d): got %v, want %v", c.k, c.count, got, c.want)
+		}
+	}
+	if got := newSeq(2).NearestK(iKey(0), 5, iDist); len(got) != 2 {
+		t.Errorf("bad NearestK count in small tree: got %d, want 2", len(got))
+	}
+}
+
+func TestPersistentInsert(t *testing.T) {
+	old := NewBasic()
+	for _, k := range [...]int{4, 2, 6, 1, 3, 5, 7} {
+		old.Insert(iKey(k), -k)
+	}
+	before := old.String()
+	s := old.PersistentInsert(iKey(8), -8)
+	if _, ok := old.Lookup(iKey(8)); ok || old.String() != before || old.Len() != 7 {
+		t.Errorf("PersistentInsert changed the old tree")
+	}
+	if v, ok := s.Lookup(iKey(8)); !ok || v.(int) != -8 || s.Len() != 8 {
+		t.Errorf("new tree lacks the inserted key")
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid new tree: %v", err)
+	}
+	if s.Child[lo] == old.Child[lo] {
+		t.Errorf("root on the insert path was not copied")
+	}
+	if s.Child[lo].Child[lo] != old.Child[lo].Child[lo] {
+		t.Errorf("untouched lo subtree was not shared")
+	}
+	if s.Get(iKey(5)) != old.Get(iKey(5)) {
+		t.Errorf("untouched node 5 was not shared")
+	}
+	u := s.PersistentInsert(iKey(2), 2)
+	if v, _ := u.Lookup(iKey(2)); v.(int) != 2 {
+		t.Errorf("bad overwritten value: %v", v)
+	}
+	if v, _ := s.Lookup(iKey(2)); v.(int) != -2 {
+		t.Errorf("overwrite leaked into the previous version: %v", v)
+	}
+}
+
+func TestDeleteReleasesValue(t *testing.T) {
+	type payload [1 << 16]byte
+	// Key 2 is a leaf, spliced out directly; key 5 has two children, so its
+	// successor 7 is copied into it and the successor's node spliced out.
+	for _, k := range [...]int{2, 5} {
+		s := NewBasic()
+		freed := make(chan int, 1)
+		for _, j := range [...]int{5, 2, 8, 7, 9} {
+			p := new(payload)
+			if j == k {
+				runtime.SetFinalizer(p, func(*payload) { freed <- k })
+			}
+			s.Insert(iKey(j), p)
+		}
+		n := s.Get(iKey(k))
+		var spliced *BasicBST
+		if k == 5 {
+			spliced = s.Get(iKey(7))
+		}
+		n.Delete()
+		if spliced != nil && (spliced.Value != nil || spliced.Parent != nil) {
+			t.Errorf("spliced successor keeps its links")
+		}
+		if k == 2 && (n.Value != nil || n.Parent != nil) {
+			t.Errorf("deleted node keeps its links")
+		}
+		released := false
+		for i := 0; i < 10 && !released; i++ {
+			runtime.GC()
+			select {
+			case <-freed:
+				released = true
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		if !released {
+			t.Errorf("value of deleted key %d still reachable", k)
+		}
+		runtime.KeepAlive(n)
+		runtime.KeepAlive(spliced)
+		runtime.KeepAlive(s)
+	}
+}
+
+func TestPop(t *testing.T) {
+	s := newSeq(100)
+	for want := 0; want < 100; want++ {
+		k, v, ok := s.PopMin()
+		if !ok || k != iKey(want) || v != -want {
+			t.Fatalf("bad PopMin: got %v/%v/%v, want %d", k, v, ok, want)
+		}
+		if s.Len() != 99-want {
+			t.Errorf("bad Len after PopMin: got %d, want %d", s.Len(), 99-want)
+		}
+	}
+	if _, _, ok := s.PopMin(); ok {
+		t.Errorf("PopMin succeeded on an empty tree")
+	}
+	s = newSeq(10)
+	for want := 9; want >= 0; want-- {
+		if k, _, ok := s.PopMax(); !ok || k != iKey(want) {
+			t.Fatalf("bad PopMax: got %v/%v, want %d", k, ok, want)
+		}
+	}
+	if _, _, ok := s.PopMax(); ok {
+		t.Errorf("PopMax succeeded on an empty tree")
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	s := newSeq(100)
+	if got := s.DeleteRange(iKey(30), iKey(60)); got != 31 {
+		t.Errorf("bad DeleteRange count: got %d, want 31", got)
+	}
+	for n := range s.Check(context.Background()) {
+		t.Errorf("violating node: %+v", *n)
+	}
+	if s.Len() != 69 {
+		t.Errorf("bad Len after DeleteRange: got %d, want 69", s.Len())
+	}
+	for k := 0; k < 100; k++ {
+		_, ok := s.Lookup(iKey(k))
+		if want := k < 30 || k > 60; ok != want {
+			t.Errorf("bad presence of %d: got %v, want %v", k, ok, want)
+		}
+	}
+	if got := s.DeleteRange(iKey(30), iKey(60)); got != 0 {
+		t.Errorf("bad repeated DeleteRange count: %d", got)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	s := newSeq(100)
+	if got := s.Trim(iKey(25), iKey(75)); got != 49 {
+		t.Errorf("bad Trim count: got %d, want 49", got)
+	}
+	for n := range s.Check(context.Background()) {
+		t.Errorf("violating node: %+v", *n)
+	}
+	if s.Len() != 51 {
+		t.Errorf("bad Len after Trim: got %d, want 51", s.Len())
+	}
+	for k := 0; k < 100; k++ {
+		_, ok := s.Lookup(iKey(k))
+		if want := k >= 25 && k <= 75; ok != want {
+			t.Errorf("bad presence of %d: got %v, want %v", k, ok, want)
+		}
+	}
+}
+
+func TestCheckAncestorBounds(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{10, 5, 7, 15} {
+		s.Insert(iKey(k), -k)
+	}
+	bad := s.Get(iKey(7))
+	bad.Key = iKey(12) // still above its parent 5, but below the root 10
+	var got []*BasicBST
+	for n := range s.Check(context.Background()) {
+		got = append(got, n)
+	}
+	if len(got) != 1 || got[0] != bad {
+		t.Errorf("bad violations: got %d nodes, want only node 12", len(got))
+	}
+	err := s.Validate()
+	if err == nil || !strings.Contains(err.Error(), "node 12:") {
+		t.Errorf("bad Validate error: %v", err)
+	}
+}
+
+func TestFold(t *testing.T) {
+	s := NewBasic()
+	for _, k := range rand.Perm(10) {
+		s.Insert(iKey(k+1), k+1)
+	}
+	sum := s.Fold(0, func(acc interface{}, k KeyType, v interface{}) interface{} {
+		return acc.(int) + v.(int)
+	})
+	if sum.(int) != 55 {
+		t.Errorf("bad sum: got %v, want 55", sum)
+	}
+	keys := s.Fold("", func(acc interface{}, k KeyType, v interface{}) interface{} {
+		return acc.(string) + k.String()
+	})
+	if keys.(string) != "12345678910" {
+		t.Errorf("bad concatenation: %v", keys)
+	}
+}
+
+func TestVerifyAcyclic(t *testing.T) {
+	s := newSeq(20)
+	s.Rebalance()
+	if err := s.VerifyAcyclic(); err != nil {
+		t.Errorf("unexpected error on valid tree: %v", err)
+	}
+	t.Run("Cycle", func(t *testing.T) {
+		s := NewBasic()
+		for _, k := range [...]int{2, 1, 3} {
+			s.Insert(iKey(k), -k)
+		}
+		three := s.Get(iKey(3))
+		three.Child[hi] = s.Child[lo] // loop back to the root
+		err := s.VerifyAcyclic()
+		if err == nil || !strings.Contains(err.Error(), "cycle") {
+			t.Errorf("bad error for cycle: %v", err)
+		}
+	})
+	t.Run("Sentinel", func(t *testing.T) {
+		s := NewBasic()
+		s.Insert(iKey(1), -1)
+		s.Child[lo].Child[lo] = s // loop back to the sentinel
+		err := s.VerifyAcyclic()
+		if err == nil || !strings.Contains(err.Error(), "node sentinel:") {
+			t.Errorf("bad error for cycle through the sentinel: %v", err)
+		}
+	})
+	t.Run("Parent", func(t *testing.T) {
+		n := s.Get(iKey(7))
+		n.Parent = s
+		err := s.VerifyAcyclic()
+		if err == nil || !strings.Contains(err.Error(), "node 7:") {
+			t.Errorf("bad error for broken Parent: %v", err)
+		}
+	})
+}
+
+func TestGetPath(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{8, 4, 12, 2, 6, 10, 14, 5} {
+		s.Insert(iKey(k), -k)
+	}
+	keys := func(path []*BasicBST) []int {
+		r := make([]int, len(path))
+		for i, n := range path {
+			r[i] = int(n.Key.(iKey))
+		}
+		return r
+	}
+	for _, c := range []struct {
+		k     int
+		want  []int
+		found bool
+	}{
+		{k: 5, want: []int{8, 4, 6, 5}, found: true},
+		{k: 8, want: []int{8}, found: true},
+		{k: 11, want: []int{8, 12, 10}, found: false},
+	} {
+		path, n := s.GetPath(iKey(c.k))
+		if len(path) == 0 || path[0] != s {
+			t.Errorf("path to %d does not start at the sentinel", c.k)
+			continue
+		}
+		if got := keys(path[1:]); fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("bad path to %d: got %v, want %v", c.k, got, c.want)
+		}
+		if found := n != nil; found != c.found || (found && n != s.Get(iKey(c.k))) {
+			t.Errorf("bad node for %d: %v", c.k, n)
+		}
+	}
+	e := NewBasic()
+	if path, n := e.GetPath(iKey(1)); len(path) != 1 || path[0] != e || n != nil {
+		t.Errorf("bad path in empty tree: got %d nodes", len(path))
+	}
+	if path, n := s.Get(iKey(4)).GetPath(iKey(5)); fmt.Sprint(keys(path)) != "[4 6 5]" || n == nil {
+		t.Errorf("bad path from an internal node: got %v", keys(path))
+	}
+}
+
+func TestVizHighlight(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{2, 1, 3} {
+		s.Insert(iKey(k), -k)
+	}
+	var b strings.Builder
+	s.Viz(&b, iKey(1), iKey(3), iKey(9))
+	dot := b.String()
+	for _, k := range [...]string{"1", "3"} {
+		if want := `[label="` + k + `", style=filled, fillcolor="yellow"];`; !strings.Contains(dot, want) {
+			t.Errorf("missing highlight for %s in:\n%s", k, dot)
+		}
+	}
+	if got := strings.Count(dot, "fillcolor"); got != 2 {
+		t.Errorf("bad highlight count: got %d, want 2", got)
+	}
+}
+
+func TestVizNodes(t *testing.T) {
+	var b strings.Builder
+	NewBasic().Viz(&b)
+	if got, want := b.String(), "digraph treemap {\n}\n"; got != want {
+		t.Errorf("bad empty DOT: got %q, want %q", got, want)
+	}
+	s := NewBasic()
+	s.Insert(iKey(7), -7)
+	b.Reset()
+	s.Viz(&b)
+	if dot := b.String(); !strings.Contains(dot, `  n1 [label="7"];`) {
+		t.Errorf("missing node declaration in:\n%s", dot)
+	}
+}
+
+func TestVizStable(t *testing.T) {
+	keys := rand.Perm(50)
+	var dots [2]string
+	for i := range dots {
+		s := NewBasic()
+		for _, k := range keys {
+			s.Insert(iKey(k), -k)
+		}
+		s.Get(iKey(keys[0])).Delete()
+		var b strings.Builder
+		s.Viz(&b, iKey(7))
+		dots[i] = b.String()
+	}
+	if dots[0] != dots[1] {
+		t.Errorf("Viz output differs:\n%s\n%s", dots[0], dots[1])
+	}
+	s := NewBasic()
+	for _, k := range keys[:5] {
+		s.Insert(iKey(k), -k)
+	}
+	for i, k := range keys[:5] {
+		if got := s.Get(iKey(k)).ID(); got != uint64(i+1) {
+			t.Errorf("bad ID of %d: got %d, want %d", k, got, i+1)
+		}
+	}
+}
+
+func TestInsertFromInternalNode(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		s := NewBasic()
+		for _, k := range [...]int{10, 5, 15} {
+			s.Insert(iKey(k), -k)
+		}
+		five := s.Get(iKey(5))
+		five.InsertFromRoot(iKey(20), -20)
+		five.Insert(iKey(12), -12)
+		if _, ok := five.GetOrInsert(iKey(11), -11); !ok {
+			t.Errorf("GetOrInsert did not insert 11")
+		}
+		if n, ok := five.GetOrInsert(iKey(15), 0); ok || n.Value != -15 {
+			t.Errorf("GetOrInsert did not find 15")
+		}
+		for n := range s.Check(context.Background()) {
+			t.Errorf("violating node: %v", n.Key)
+		}
+		for _, k := range [...]int{11, 12, 20} {
+			if _, ok := s.Lookup(iKey(k)); !ok {
+				t.Errorf("missing key %d", k)
+			}
+		}
+		if five.Len() != 1 {
+			t.Errorf("keys were placed under the internal node")
+		}
+	})
+	t.Run("avl", func(t *testing.T) {
+		s := NewAVL()
+		for _, k := range [...]int{10, 5, 15} {
+			s.Insert(iKey(k), -k)
+		}
+		five := s.Get(iKey(5))
+		five.Insert(iKey(20), -20)
+		five.InsertR(iKey(12), -12)
+		if _, ok := five.GetOrInsert(iKey(11), -11); !ok {
+			t.Errorf("GetOrInsert did not insert 11")
+		}
+		if n, ok := five.GetOrInsert(iKey(15), 0); ok || n.Value != -15 {
+			t.Errorf("GetOrInsert did not find 15")
+		}
+		if err := s.Validate(); err != nil {
+			t.Errorf("invalid tree: %v", err)
+		}
+		for _, k := range [...]int{11, 12, 20} {
+			if s.Get(iKey(k)) == nil {
+				t.Errorf("missing key %d", k)
+			}
+		}
+	})
+}
+
+func TestInsertOnDeletedNode(t *testing.T) {
+	t.Run("basic", func(t *testing.T) {
+		s := NewBasic()
+		for _, k := range [...]int{5, 3, 8} {
+			s.Insert(iKey(k), -k)
+		}
+		n := s.Get(iKey(3))
+		n.Delete()
+		if c := n.Insert(iKey(20), -20); c != nil {
+			t.Errorf("Insert on deleted node returned %v", c.Key)
+		}
+		if c, ok := n.GetOrInsert(iKey(21), -21); c != nil || ok {
+			t.Errorf("GetOrInsert on deleted node inserted")
+		}
+		if err := n.InsertGuarded(iKey(22), -22, 100); !errors.Is(err, ErrDetached) {
+			t.Errorf("bad InsertGuarded error: %v", err)
+		}
+		if c := n.Cursor(); c.Next() {
+			t.Errorf("Cursor on deleted node found %v", c.Key())
+		}
+		if s.Len() != 2 {
+			t.Errorf("bad Len: got %d, want 2", s.Len())
+		}
+	})
+	t.Run("avl", func(t *testing.T) {
+		s := NewAVL()
+		for k := 0; k < 10; k++ {
+			s.Insert(iKey(k), -k)
+		}
+		n := s.Get(iKey(9))
+		n.Delete()
+		n.Insert(iKey(20), -20)
+		if n.InsertR(iKey(21), -21) {
+			t.Errorf("InsertR on deleted node rotated")
+		}
+		if c, ok := n.GetOrInsert(iKey(22), -22); c != nil || ok {
+			t.Errorf("GetOrInsert on deleted node inserted")
+		}
+		if err := s.Validate(); err != nil {
+			t.Errorf("invalid tree: %v", err)
+		}
+		for _, k := range [...]int{20, 21, 22} {
+			if s.Get(iKey(k)) != nil {
+				t.Errorf("key %d inserted through deleted node", k)
+			}
+		}
+	})
+}
+
+func TestDeepGet(t *testing.T) {
+	const depth = 1000000
+	s := NewBasic()
+	parent, d := s, lo
+	for k := 0; k < depth; k++ {
+		n := &BasicBST{Key: iKey(k), Value: -k, Parent: parent}
+		parent.Child[d] = n
+		parent, d = n, hi
+	}
+	n := s.Get(iKey(depth - 1))
+	if n == nil || n.Value.(int) != -(depth-1) {
+		t.Errorf("bad deepest Get")
+	}
+	if n := s.Get(iKey(depth)); n != nil {
+		t.Errorf("unexpected Get past the end")
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	s := NewBasic()
+	for _, k := range rand.Perm(2000) {
+		if k%2 == 0 {
+			s.Insert(iKey(k), -k)
+		}
+	}
+	keys := make([]KeyType, 1000)
+	for i := range keys {
+		keys[i] = iKey(rand.Intn(2000))
+	}
+	check := func(t *testing.T, found map[KeyType]interface{}) {
+		for _, k := range keys {
+			v, ok := found[k]
+			if n := s.Get(k); (n != nil) != ok || n != nil && n.Value != v {
+				t.Errorf("bad GetMany of %v: got %v/%v", k, v, ok)
+			}
+		}
+	}
+	t.Run("unsorted", func(t *testing.T) {
+		check(t, s.GetMany(keys))
+	})
+	t.Run("sorted", func(t *testing.T) {
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Less(keys[j]) })
+		check(t, s.GetMany(keys))
+	})
+}
+
+func TestDeepKeys(t *testing.T) {
+	const depth = 1000000
+	s := NewBasic()
+	parent := s
+	for k := depth - 1; k >= 0; k-- {
+		n := &BasicBST{Key: iKey(k), Value: -k, Parent: parent}
+		parent.Child[lo] = n
+		parent = n
+	}
+	want := 0
+	for k := range s.Keys(context.Background()) {
+		if got := int(k.(iKey)); got != want {
+			t.Fatalf("bad key: got %d, want %d", got, want)
+		}
+		want++
+	}
+	if want != depth {
+		t.Errorf("bad key count: got %d, want %d", want, depth)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDeepWalks(t *testing.T) {
+	const depth = 1000000
+	s := NewBasic()
+	parent, d := s, lo
+	for k := 0; k < depth; k++ {
+		n := &BasicBST{Key: iKey(k), Value: -k, Parent: parent, info: s.info}
+		parent.Child[d] = n
+		parent, d = n, hi
+	}
+	s.MapValues(func(_ KeyType, v interface{}) interface{} {
+		return v
+	})
+	if n := s.Len(); n != depth {
+		t.Errorf("bad Len after refresh: got %d, want %d", n, depth)
+	}
+	if h := s.Height(); h != depth-1 {
+		t.Errorf("bad Height: got %d, want %d", h, depth-1)
+	}
+	if hist := s.BalanceHistogram(); len(hist) != depth || hist[0] != 1 || hist[1-depth] != 1 {
+		t.Errorf("bad BalanceHistogram: got %d balance factors, want %d", len(hist), depth)
+	}
+	want := 0
+	s.WalkDetailed(func(n *BasicBST, depth, side int) error {
+		if depth != want || (depth == 0) != (side == -1) || depth > 0 && side != hi {
+			t.Fatalf("bad walk of %v: got depth %d side %d", n.Key, depth, side)
+		}
+		want++
+		return nil
+	})
+	if want != depth {
+		t.Errorf("bad walk count: got %d, want %d", want, depth)
+	}
+}
+
+func TestKeysBuffered(t *testing.T) {
+	s := newSeq(1000)
+	t.Run("all", func(t *testing.T) {
+		want := 0
+		for k := range s.KeysBuffered(context.Background(), 64) {
+			if got := int(k.(iKey)); got != want {
+				t.Errorf("bad key: got %d, want %d", got, want)
+			}
+			want++
+		}
+		if want != 1000 {
+			t.Errorf("bad key count: got %d, want 1000", want)
+		}
+	})
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		keys := s.KeysBuffered(ctx, 64)
+		got := 0
+		for range keys {
+			if got++; got == 10 {
+				cancel()
+			}
+		}
+		if got == 1000 {
+			t.Errorf("cancel did not stop the stream")
+		}
+	})
+}
+
+func TestKeysWhere(t *testing.T) {
+	s := newSeq(20)
+	even := func(k KeyType, v interface{}) bool {
+		return k.(iKey)%2 == 0 && v.(int) == -int(k.(iKey))
+	}
+	var got []int
+	for k := range s.KeysWhere(context.Background(), even) {
+		got = append(got, int(k.(iKey)))
+	}
+	if want := []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("bad keys: got %v, want %v", got, want)
+	}
+	// Once cancelled the producer may still win a few selects against
+	// ctx.Done, each with even odds, but must close the channel long before
+	// the 5000 matching keys of a larger tree run out.
+	const bound = 64
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	keys := newSeq(10000).KeysWhere(ctx, even)
+	<-keys
+	cancel()
+	rest := 0
+	for range keys {
+		if rest++; rest > bound {
+			t.Fatalf("channel still open after %d keys past the cancel", bound)
+		}
+	}
+}
+
+func BenchmarkKeys(b *testing.B) {
+	s := newSeq(100000)
+	for _, size := range []int{0, 1024} {
+		b.Run(fmt.Sprintf("buffer%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for range s.KeysBuffered(context.Background(), size) {
+				}
+			}
+		})
+	}
+}
+
+func TestKeysRange(t *testing.T) {
+	s := newSeq(100)
+	want := 10
+	for k := range s.KeysRange(context.Background(), 10, 20) {
+		if got := int(k.(iKey)); got != want {
+			t.Errorf("bad key: got %d, want %d", got, want)
+		}
+		want++
+	}
+	if want != 20 {
+		t.Errorf("bad end of range: got %d, want 20", want)
+	}
+	count := 0
+	for range s.KeysRange(context.Background(), 95, 200) {
+		count++
+	}
+	if count != 5 {
+		t.Errorf("bad count past the end: got %d, want 5", count)
+	}
+	for k := range s.KeysRange(context.Background(), 20, 10) {
+		t.Errorf("unexpected key in empty range: %v", k)
+	}
+}
+
+func TestDeleteChecked(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{4, 2, 6, 1, 3, 5, 7} {
+		s.Insert(iKey(k), -k)
+	}
+	if err := s.Get(iKey(1)).DeleteChecked(); err != nil {
+		t.Errorf("unexpected error deleting a leaf: %v", err)
+	}
+	three := s.Get(iKey(3))
+	three.Parent = s.Get(iKey(6))
+	err := three.DeleteChecked()
+	if err == nil || !strings.Contains(err.Error(), "node 3:") {
+		t.Errorf("bad error for broken Parent: %v", err)
+	}
+	if s.Get(iKey(3)) != three || s.Len() != 6 {
+		t.Errorf("failed DeleteChecked changed the tree")
+	}
+	seven := s.Get(iKey(7))
+	seven.Parent = nil
+	if err := s.Get(iKey(6)).DeleteChecked(); err == nil {
+		t.Errorf("missing error for successor with nil Parent")
+	}
+}
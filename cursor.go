@@ -0,0 +1,42 @@
+package bst
+
+// Cursor steps through a BasicBST's pairs from low to high on demand, without
+// goroutines or channels. It starts before the first pair.
+type Cursor struct {
+	tree    *BasicBST // the sentinel
+	cur     *BasicBST // the node at the cursor, nil before the start or after the end
+	pending *BasicBST // the node Next moves to
+}
+
+// Cursor returns a Cursor positioned before the first pair of n's tree. On a
+// node no longer in a tree it returns a Cursor with no pairs.
+func (n *BasicBST) Cursor() *Cursor {
+	t := n.top()
+	return &Cursor{tree: t, pending: t.Select(0)}
+}
+
+// Next advances to the next pair, returning false when there are no more.
+func (c *Cursor) Next() bool {
+	c.cur = c.pending
+	if c.cur != nil {
+		c.pending = c.cur.Next()
+	}
+	return c.cur != nil
+}
+
+// Key returns the key of the current pair.
+func (c *Cursor) Key() KeyType {
+	return c.cur.Key
+}
+
+// Value returns the value of the current pair.
+func (c *Cursor) Value() interface{} {
+	return c.cur.Value
+}
+
+// SeekTo repositions the cursor so that the following Next moves to the
+// first pair whose key is not less than k.
+func (c *Cursor) SeekTo(k KeyType) {
+	c.cur = nil
+	c.pending = c.tree.LowerBound(k)
+}
@@ -0,0 +1,37 @@
+package bst
+
+import (
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{4, 2, 6, 1, 3, 5, 7} {
+		s.Insert(iKey(k), -k)
+	}
+	c := s.Cursor()
+	for want := 1; want <= 3; want++ {
+		if !c.Next() {
+			t.Fatalf("cursor ended early at %d", want)
+		}
+		if k, v := int(c.Key().(iKey)), c.Value().(int); k != want || v != -want {
+			t.Errorf("bad cursor pair: got %d, %d, want %d, %d", k, v, want, -want)
+		}
+	}
+	c.SeekTo(iKey(5))
+	for want := 5; want <= 7; want++ {
+		if !c.Next() || int(c.Key().(iKey)) != want {
+			t.Errorf("bad cursor key after seek, want %d", want)
+		}
+	}
+	if c.Next() || c.Next() {
+		t.Errorf("cursor did not end after the last pair")
+	}
+	c.SeekTo(iKey(0))
+	if !c.Next() || c.Key().(iKey) != 1 {
+		t.Errorf("bad reseek to the start")
+	}
+	if NewBasic().Cursor().Next() {
+		t.Errorf("cursor over empty tree has a pair")
+	}
+}
@@ -0,0 +1,105 @@
+package bst
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// BinaryKey is a KeyType that Encode can write.
+type BinaryKey interface {
+	KeyType
+	encoding.BinaryMarshaler
+}
+
+// Encode encodes the tree's pairs from low to high in a compact binary form:
+// the pair count, then each key and value, all length-prefixed with uvarints.
+// Keys must be BinaryKeys and values encoding.BinaryMarshalers.
+func (n *BasicBST) Encode() ([]byte, error) {
+	buf := binary.AppendUvarint(nil, uint64(n.Len()))
+	err := n.Visit(func(n *BasicBST) error {
+		fields, err := marshalPair(n.Key, n.Value)
+		if err != nil {
+			return err
+		}
+		for _, b := range fields {
+			buf = binary.AppendUvarint(buf, uint64(len(b)))
+			buf = append(buf, b...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// marshalPair marshals a key, which must be a BinaryKey, and a value, which
+// must be an encoding.BinaryMarshaler.
+func marshalPair(k KeyType, v interface{}) ([2][]byte, error) {
+	var fields [2][]byte
+	bk, ok := k.(BinaryKey)
+	if !ok {
+		return fields, fmt.Errorf("key %s: %T is not a BinaryKey", k, k)
+	}
+	bv, ok := v.(encoding.BinaryMarshaler)
+	if !ok {
+		return fields, fmt.Errorf("key %s: value %T is not a BinaryMarshaler", k, v)
+	}
+	for i, m := range [...]encoding.BinaryMarshaler{bk, bv} {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return fields, fmt.Errorf("key %s: %w", k, err)
+		}
+		fields[i] = b
+	}
+	return fields, nil
+}
+
+var errTruncated = errors.New("truncated encoding")
+
+// DecodeBasic builds a balanced BasicBST from data written by Encode, using
+// key and value to decode each pair's fields.
+func DecodeBasic(data []byte, key func([]byte) (KeyType, error), value func([]byte) (interface{}, error)) (*BasicBST, error) {
+	field := func() ([]byte, error) {
+		size, w := binary.Uvarint(data)
+		if w <= 0 || uint64(len(data)-w) < size {
+			return nil, errTruncated
+		}
+		b := data[w : w+int(size)]
+		data = data[w+int(size):]
+		return b, nil
+	}
+	count, w := binary.Uvarint(data)
+	if w <= 0 || count > uint64(len(data)) {
+		return nil, errTruncated
+	}
+	data = data[w:]
+	pairs := make([]Pair, count)
+	for i := range pairs {
+		kb, err := field()
+		if err != nil {
+			return nil, err
+		}
+		vb, err := field()
+		if err != nil {
+			return nil, err
+		}
+		if pairs[i].Key, err = key(kb); err != nil {
+			return nil, err
+		}
+		if pairs[i].Value, err = value(vb); err != nil {
+			return nil, err
+		}
+		if i > 0 && !pairs[i-1].Key.Less(pairs[i].Key) {
+			return nil, fmt.Errorf("key %s: out of order", pairs[i].Key)
+		}
+	}
+	if len(data) != 0 {
+		return nil, fmt.Errorf("%d bytes of trailing data", len(data))
+	}
+	t := NewBasic()
+	t.Child[lo] = buildBasic(pairs, t)
+	return t, nil
+}
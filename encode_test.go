@@ -0,0 +1,49 @@
+package bst
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	s := NewBasic()
+	for _, k := range rand.Perm(1000) {
+		s.Insert(IntKey(k), IntKey(-k))
+	}
+	data, err := s.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	value := func(b []byte) (interface{}, error) {
+		return DecodeIntKey(b)
+	}
+	d, err := DecodeBasic(data, DecodeIntKey, value)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if d.Len() != 1000 || d.Height() != 9 {
+		t.Errorf("bad decoded tree: %d keys, height %d", d.Len(), d.Height())
+	}
+	want := s.ToSlice()
+	for i, p := range d.ToSlice() {
+		if p != want[i] {
+			t.Errorf("bad pair at %d: got %+v, want %+v", i, p, want[i])
+		}
+	}
+	js, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("JSON encoding failed: %v", err)
+	}
+	t.Logf("binary %d bytes, JSON %d bytes", len(data), len(js))
+	if len(data) >= len(js) {
+		t.Errorf("binary encoding is no smaller than JSON")
+	}
+	if _, err := DecodeBasic(data[:len(data)-1], DecodeIntKey, value); err == nil {
+		t.Errorf("missing error decoding truncated data")
+	}
+	s.Insert(IntKey(5000), 5000)
+	if _, err := s.Encode(); err == nil {
+		t.Errorf("missing error encoding an int value")
+	}
+}
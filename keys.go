@@ -0,0 +1,183 @@
+package bst
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// IntKey is a KeyType for int keys.
+type IntKey int
+
+func (a IntKey) Equal(b KeyType) bool {
+	return a == b.(IntKey)
+}
+
+func (a IntKey) Less(b KeyType) bool {
+	return a < b.(IntKey)
+}
+
+func (a IntKey) String() string {
+	return strconv.Itoa(int(a))
+}
+
+// MarshalBinary encodes the key as a varint.
+func (a IntKey) MarshalBinary() ([]byte, error) {
+	return binary.AppendVarint(nil, int64(a)), nil
+}
+
+// DecodeIntKey decodes an IntKey written by MarshalBinary.
+func DecodeIntKey(b []byte) (KeyType, error) {
+	k, w := binary.Varint(b)
+	if w != len(b) {
+		return nil, errors.New("bad IntKey encoding")
+	}
+	return IntKey(k), nil
+}
+
+// StringKey is a KeyType for string keys, ordered bytewise.
+type StringKey string
+
+func (a StringKey) Equal(b KeyType) bool {
+	return a == b.(StringKey)
+}
+
+func (a StringKey) Less(b KeyType) bool {
+	return a < b.(StringKey)
+}
+
+func (a StringKey) String() string {
+	return string(a)
+}
+
+// MarshalBinary encodes the key as its bytes.
+func (a StringKey) MarshalBinary() ([]byte, error) {
+	return []byte(a), nil
+}
+
+// DecodeStringKey decodes a StringKey written by MarshalBinary.
+func DecodeStringKey(b []byte) (KeyType, error) {
+	return StringKey(b), nil
+}
+
+// StringKeyFold is a KeyType for string keys ignoring case: keys are
+// compared by their strings.ToLower forms, so "Apple" and "apple" are the
+// same key, ordered between "ant" and "Banana". The key keeps its original
+// spelling.
+type StringKeyFold string
+
+func (a StringKeyFold) Equal(b KeyType) bool {
+	return strings.ToLower(string(a)) == strings.ToLower(string(b.(StringKeyFold)))
+}
+
+func (a StringKeyFold) Less(b KeyType) bool {
+	return strings.ToLower(string(a)) < strings.ToLower(string(b.(StringKeyFold)))
+}
+
+func (a StringKeyFold) String() string {
+	return string(a)
+}
+
+// MarshalBinary encodes the key as its bytes.
+func (a StringKeyFold) MarshalBinary() ([]byte, error) {
+	return []byte(a), nil
+}
+
+// DecodeStringKeyFold decodes a StringKeyFold written by MarshalBinary.
+func DecodeStringKeyFold(b []byte) (KeyType, error) {
+	return StringKeyFold(b), nil
+}
+
+// Collation orders strings for CollatedKey, returning a negative number if a
+// sorts before b, zero if they are the same key, and a positive number if a
+// sorts after b. It must be a strict weak ordering; see CheckKeyOrdering.
+type Collation func(a, b string) int
+
+// Key returns s as a key ordered by c.
+func (c Collation) Key(s string) CollatedKey {
+	return CollatedKey{S: s, By: c}
+}
+
+// CollatedKey is a KeyType for string keys ordered by a Collation, such as a
+// locale's. All the keys of a tree must share the same Collation.
+type CollatedKey struct {
+	S  string
+	By Collation
+}
+
+func (a CollatedKey) Equal(b KeyType) bool {
+	return a.By(a.S, b.(CollatedKey).S) == 0
+}
+
+func (a CollatedKey) Less(b KeyType) bool {
+	return a.By(a.S, b.(CollatedKey).S) < 0
+}
+
+func (a CollatedKey) String() string {
+	return a.S
+}
+
+// Float64Key is a KeyType for float64 keys. To keep the ordering total, NaN
+// is equal to NaN and less than every other value, including -Inf.
+type Float64Key float64
+
+func (a Float64Key) Equal(b KeyType) bool {
+	x, y := float64(a), float64(b.(Float64Key))
+	return x == y || (math.IsNaN(x) && math.IsNaN(y))
+}
+
+func (a Float64Key) Less(b KeyType) bool {
+	x, y := float64(a), float64(b.(Float64Key))
+	return x < y || (math.IsNaN(x) && !math.IsNaN(y))
+}
+
+func (a Float64Key) String() string {
+	return strconv.FormatFloat(float64(a), 'g', -1, 64)
+}
+
+// MarshalBinary encodes the key as its IEEE 754 bits.
+func (a Float64Key) MarshalBinary() ([]byte, error) {
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(float64(a))), nil
+}
+
+// DecodeFloat64Key decodes a Float64Key written by MarshalBinary.
+func DecodeFloat64Key(b []byte) (KeyType, error) {
+	if len(b) != 8 {
+		return nil, errors.New("bad Float64Key encoding")
+	}
+	return Float64Key(math.Float64frombits(binary.BigEndian.Uint64(b))), nil
+}
+
+// CheckKeyOrdering checks that Less and Equal behave as a strict weak
+// ordering should across every combination of samples: Less is irreflexive,
+// asymmetric and transitive, and Equal holds exactly when neither key is
+// Less than the other. It returns an error describing the first violation
+// found, or nil. It takes cubic time, so is meant for tests of a KeyType
+// implementation rather than for production use.
+func CheckKeyOrdering(samples []KeyType) error {
+	for _, a := range samples {
+		if a.Less(a) {
+			return fmt.Errorf("key %s: Less is not irreflexive, %s < %s", a, a, a)
+		}
+		for _, b := range samples {
+			ab, ba := a.Less(b), b.Less(a)
+			switch {
+			case ab && ba:
+				return fmt.Errorf("key %s: Less is not asymmetric, %s < %s and %s < %s", a, a, b, b, a)
+			case a.Equal(b) != (!ab && !ba):
+				return fmt.Errorf("key %s: Equal(%s) is %v, but Less disagrees", a, b, a.Equal(b))
+			case !ab:
+				continue
+			}
+			for _, c := range samples {
+				if b.Less(c) && !a.Less(c) {
+					return fmt.Errorf("key %s: Less is not transitive, %s < %s < %s but not %s < %s", a, a, b, c, a, c)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package bst
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIntKey(t *testing.T) {
+	s := NewBasic()
+	for _, k := range rand.Perm(50) {
+		s.Insert(IntKey(k-25), k)
+	}
+	want := IntKey(-25)
+	for k := range s.Keys(context.Background()) {
+		if k.(IntKey) != want {
+			t.Errorf("bad key: got %v, want %v", k, want)
+		}
+		want++
+	}
+	if v, ok := s.Lookup(IntKey(-3)); !ok || v.(int) != 22 {
+		t.Errorf("bad Lookup(-3): got %v, %v", v, ok)
+	}
+}
+
+func TestStringKey(t *testing.T) {
+	s := NewBasic()
+	for _, k := range []string{"pear", "Zebra", "apple", "fig", ""} {
+		s.Insert(StringKey(k), len(k))
+	}
+	want := []string{"", "Zebra", "apple", "fig", "pear"}
+	i := 0
+	for k := range s.Keys(context.Background()) {
+		if got := k.String(); got != want[i] {
+			t.Errorf("bad key at %d: got %q, want %q", i, got, want[i])
+		}
+		i++
+	}
+	if v, ok := s.Lookup(StringKey("fig")); !ok || v.(int) != 3 {
+		t.Errorf("bad Lookup(fig): got %v, %v", v, ok)
+	}
+	if _, ok := s.Lookup(StringKey("Fig")); ok {
+		t.Errorf("unexpected Lookup(Fig)")
+	}
+}
+
+func TestStringKeyFold(t *testing.T) {
+	words := []string{"banana", "Apple", "cherry", "apple", "BANANA", "ant"}
+	keys := func(s *BasicBST) []string {
+		var ks []string
+		for _, k := range s.AllKeys() {
+			ks = append(ks, k.String())
+		}
+		return ks
+	}
+	plain, fold := NewBasic(), NewBasic()
+	for i, w := range words {
+		plain.Insert(StringKey(w), i)
+		fold.Insert(StringKeyFold(w), i)
+	}
+	if got, want := keys(plain), []string{"Apple", "BANANA", "ant", "apple", "banana", "cherry"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("bad plain order: got %q, want %q", got, want)
+	}
+	if got, want := keys(fold), []string{"ant", "Apple", "banana", "cherry"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("bad folded order: got %q, want %q", got, want)
+	}
+	for _, c := range []struct {
+		k    string
+		want int
+	}{{"APPLE", 3}, {"Banana", 4}, {"Cherry", 2}} {
+		if v, ok := fold.Lookup(StringKeyFold(c.k)); !ok || v != c.want {
+			t.Errorf("bad folded Lookup(%s): got %v/%v, want %d", c.k, v, ok, c.want)
+		}
+		if _, ok := plain.Lookup(StringKey(c.k)); ok {
+			t.Errorf("unexpected plain Lookup(%s)", c.k)
+		}
+	}
+	var samples []KeyType
+	for _, w := range words {
+		samples = append(samples, StringKeyFold(w))
+	}
+	if err := CheckKeyOrdering(samples); err != nil {
+		t.Errorf("bad folded ordering: %v", err)
+	}
+}
+
+func TestCollatedKey(t *testing.T) {
+	// shortest first, then bytewise
+	byLength := Collation(func(a, b string) int {
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		return strings.Compare(a, b)
+	})
+	s := NewBasic()
+	for _, w := range []string{"pear", "fig", "banana", "kiwi", "fig"} {
+		s.Insert(byLength.Key(w), len(w))
+	}
+	var got []string
+	for _, k := range s.AllKeys() {
+		got = append(got, k.String())
+	}
+	if want := "fig kiwi pear banana"; strings.Join(got, " ") != want {
+		t.Errorf("bad collated order: got %q, want %q", got, want)
+	}
+	if v, ok := s.Lookup(byLength.Key("kiwi")); !ok || v != 4 {
+		t.Errorf("bad Lookup(kiwi): got %v/%v", v, ok)
+	}
+}
+
+func TestFloat64Key(t *testing.T) {
+	nan := Float64Key(math.NaN())
+	if !nan.Equal(nan) || nan.Less(nan) {
+		t.Errorf("NaN is not equal to itself")
+	}
+	if !nan.Less(Float64Key(math.Inf(-1))) {
+		t.Errorf("NaN is not less than -Inf")
+	}
+	s := NewBasic()
+	for _, k := range []float64{2.5, math.NaN(), -1, 0} {
+		s.Insert(Float64Key(k), k)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid tree: %v", err)
+	}
+	if n := s.Select(0); n == nil || !n.Key.Equal(nan) {
+		t.Errorf("NaN is not the lowest key")
+	}
+	if _, ok := s.Lookup(nan); !ok {
+		t.Errorf("NaN key not found")
+	}
+}
+
+// sloppyKey orders by value with <=, so Less is not irreflexive.
+type sloppyKey int
+
+func (a sloppyKey) Equal(b KeyType) bool { return a == b.(sloppyKey) }
+func (a sloppyKey) Less(b KeyType) bool  { return a <= b.(sloppyKey) }
+func (a sloppyKey) String() string       { return strconv.Itoa(int(a)) }
+
+// lastDigitKey orders by value but compares equal by last digit.
+type lastDigitKey int
+
+func (a lastDigitKey) Equal(b KeyType) bool { return a%10 == b.(lastDigitKey)%10 }
+func (a lastDigitKey) Less(b KeyType) bool  { return a < b.(lastDigitKey) }
+func (a lastDigitKey) String() string       { return strconv.Itoa(int(a)) }
+
+func TestCheckKeyOrdering(t *testing.T) {
+	var good, sloppy, digits []KeyType
+	for _, k := range rand.Perm(30) {
+		good = append(good, IntKey(k))
+		sloppy = append(sloppy, sloppyKey(k))
+		digits = append(digits, lastDigitKey(k))
+	}
+	floats := []KeyType{Float64Key(math.NaN()), Float64Key(-1), Float64Key(0), Float64Key(2.5), Float64Key(math.Inf(1))}
+	for _, samples := range [][]KeyType{good, floats} {
+		if err := CheckKeyOrdering(samples); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	for _, c := range []struct {
+		name    string
+		samples []KeyType
+		want    string
+	}{
+		{"sloppy", sloppy, "not irreflexive"},
+		{"digits", digits, "Less disagrees"},
+	} {
+		err := CheckKeyOrdering(c.samples)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("bad %s error: got %v, want one containing %q", c.name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package bst
+
+import (
+	"container/heap"
+	"context"
+)
+
+// mergeHead is the next unmerged node of one tree.
+type mergeHead struct {
+	n    *BasicBST
+	tree int // index of the tree among those merged
+}
+
+// mergeHeap orders heads by key, then by tree index.
+type mergeHeap []mergeHead
+
+func (h mergeHeap) Len() int {
+	return len(h)
+}
+
+func (h mergeHeap) Less(i, j int) bool {
+	a, b := h[i], h[j]
+	if a.n.Key.Less(b.n.Key) {
+		return true
+	}
+	return !b.n.Key.Less(a.n.Key) && a.tree < b.tree
+}
+
+func (h mergeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *mergeHeap) Push(x interface{}) {
+	*h = append(*h, x.(mergeHead))
+}
+
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// MergeIter streams the pairs of all the trees in ascending key order,
+// without building a combined tree. A key held by several trees is sent once,
+// with its value from the last of them in the argument list.
+func MergeIter(ctx context.Context, trees ...*BasicBST) chan Pair {
+	pairs := make(chan Pair)
+	go func() {
+		defer close(pairs)
+		h := make(mergeHeap, 0, len(trees))
+		for i, t := range trees {
+			if n := t.Select(0); n != nil {
+				h = append(h, mergeHead{n: n, tree: i})
+			}
+		}
+		heap.Init(&h)
+		for h.Len() > 0 {
+			top := heap.Pop(&h).(mergeHead)
+			p := Pair{Key: top.n.Key, Value: top.n.Value}
+			for {
+				if next := top.n.Next(); next != nil {
+					heap.Push(&h, mergeHead{n: next, tree: top.tree})
+				}
+				if h.Len() == 0 || !h[0].n.Key.Equal(p.Key) {
+					break
+				}
+				top = heap.Pop(&h).(mergeHead)
+				p.Value = top.n.Value
+			}
+			select {
+			case pairs <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return pairs
+}
@@ -0,0 +1,58 @@
+package bst
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMergeIter(t *testing.T) {
+	trees := []*BasicBST{NewBasic(), NewBasic(), NewBasic()}
+	for k := 0; k < 30; k++ {
+		trees[k%3].Insert(iKey(k), k)
+	}
+	want := 0
+	for p := range MergeIter(context.Background(), trees...) {
+		if int(p.Key.(iKey)) != want || p.Value.(int) != want {
+			t.Errorf("bad merged pair: got %+v, want %d", p, want)
+		}
+		want++
+	}
+	if want != 30 {
+		t.Errorf("bad merged count: got %d, want 30", want)
+	}
+
+	a, b := NewBasic(), NewBasic()
+	for k := 0; k < 10; k++ {
+		a.Insert(iKey(k), "a")
+	}
+	for k := 5; k < 15; k++ {
+		b.Insert(iKey(k), "b")
+	}
+	want = 0
+	for p := range MergeIter(context.Background(), a, b) {
+		wantValue := "a"
+		if want >= 5 {
+			wantValue = "b"
+		}
+		if int(p.Key.(iKey)) != want || p.Value.(string) != wantValue {
+			t.Errorf("bad merged pair: got %+v, want %d:%s", p, want, wantValue)
+		}
+		want++
+	}
+	if want != 15 {
+		t.Errorf("bad deduplicated count: got %d, want 15", want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := 0
+	for range MergeIter(ctx, trees...) {
+		got++
+		if got == 3 {
+			cancel()
+		}
+	}
+	if got < 3 || got > 30 {
+		t.Errorf("bad count with cancel: %d pairs", got)
+	}
+}
@@ -0,0 +1,29 @@
+package bst
+
+// SetMeta sets the metadata entry name for n's key to v.
+func (n *BasicBST) SetMeta(name string, v interface{}) {
+	if n.Meta == nil {
+		n.Meta = make(map[string]interface{})
+	}
+	n.Meta[name] = v
+}
+
+// GetMeta returns the metadata entry name for n's key and whether it is set.
+func (n *BasicBST) GetMeta(name string) (interface{}, bool) {
+	v, ok := n.Meta[name]
+	return v, ok
+}
+
+// SetMeta sets the metadata entry name for n's key to v.
+func (n *AVL) SetMeta(name string, v interface{}) {
+	if n.Meta == nil {
+		n.Meta = make(map[string]interface{})
+	}
+	n.Meta[name] = v
+}
+
+// GetMeta returns the metadata entry name for n's key and whether it is set.
+func (n *AVL) GetMeta(name string) (interface{}, bool) {
+	v, ok := n.Meta[name]
+	return v, ok
+}
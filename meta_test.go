@@ -0,0 +1,72 @@
+package bst
+
+import "testing"
+
+func TestMeta(t *testing.T) {
+	t.Run("rotation", func(t *testing.T) {
+		s := NewAVL()
+		s.Insert(iKey(0), 0)
+		s.Get(iKey(0)).SetMeta("tag", "zero")
+		for k := 1; k < 10; k++ {
+			s.Insert(iKey(k), -k)
+		}
+		if s.Stats().Rotations == 0 {
+			t.Fatalf("no rotation happened")
+		}
+		if v, ok := s.Get(iKey(0)).GetMeta("tag"); !ok || v != "zero" {
+			t.Errorf("bad tag after rotations: got %v/%v", v, ok)
+		}
+		if _, ok := s.Get(iKey(1)).GetMeta("tag"); ok {
+			t.Errorf("tag leaked to another key")
+		}
+	})
+	t.Run("delete", func(t *testing.T) {
+		s := NewBasic()
+		for _, k := range []int{5, 2, 8, 7, 9} {
+			s.Insert(iKey(k), -k)
+		}
+		s.Get(iKey(7)).SetMeta("tag", "seven")
+		n := s.Get(iKey(5))
+		n.Delete() // copies its successor 7 into n
+		if n.Key != iKey(7) {
+			t.Fatalf("bad successor copy: got %v, want 7", n.Key)
+		}
+		if v, ok := s.Get(iKey(7)).GetMeta("tag"); !ok || v != "seven" {
+			t.Errorf("bad tag after Delete: got %v/%v", v, ok)
+		}
+	})
+	t.Run("rebalance", func(t *testing.T) {
+		s := NewBasic()
+		for k := 0; k < 10; k++ {
+			s.Insert(iKey(k), -k)
+		}
+		s.Get(iKey(0)).SetMeta("tag", "zero")
+		s.Rebalance()
+		if v, ok := s.Get(iKey(0)).GetMeta("tag"); !ok || v != "zero" {
+			t.Errorf("bad tag after Rebalance: got %v/%v", v, ok)
+		}
+		f := s.Filter(func(k KeyType, _ interface{}) bool { return k.(iKey) < 5 })
+		if v, ok := f.Get(iKey(0)).GetMeta("tag"); !ok || v != "zero" {
+			t.Errorf("bad tag after Filter: got %v/%v", v, ok)
+		}
+		f.Get(iKey(0)).SetMeta("tag", "copy")
+		if v, _ := s.Get(iKey(0)).GetMeta("tag"); v != "zero" {
+			t.Errorf("bad tag in source after Filter copy changed: got %v", v)
+		}
+	})
+	t.Run("persistent", func(t *testing.T) {
+		s := NewBasic()
+		for _, k := range []int{5, 2, 8} {
+			s.Insert(iKey(k), -k)
+		}
+		s.Get(iKey(2)).SetMeta("tag", "two")
+		p := s.PersistentInsert(iKey(1), -1)
+		if v, ok := p.Get(iKey(2)).GetMeta("tag"); !ok || v != "two" {
+			t.Errorf("bad tag in new version: got %v/%v", v, ok)
+		}
+		p.Get(iKey(2)).SetMeta("tag", "new")
+		if v, _ := s.Get(iKey(2)).GetMeta("tag"); v != "two" {
+			t.Errorf("bad tag in old version after new one changed: got %v", v)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package bst
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// orderedKey adapts a value of a built-in ordered type to KeyType.
+type orderedKey[K cmp.Ordered] struct {
+	k K
+}
+
+func (a orderedKey[K]) Equal(b KeyType) bool {
+	return cmp.Compare(a.k, b.(orderedKey[K]).k) == 0
+}
+
+func (a orderedKey[K]) Less(b KeyType) bool {
+	return cmp.Less(a.k, b.(orderedKey[K]).k)
+}
+
+func (a orderedKey[K]) String() string {
+	return fmt.Sprint(a.k)
+}
+
+// Ordered is a BasicBST keyed directly by a built-in ordered type, so that
+// callers need not implement KeyType.
+type Ordered[K cmp.Ordered] struct {
+	tree *BasicBST
+}
+
+// NewOrdered allocates a new Ordered tree.
+func NewOrdered[K cmp.Ordered]() *Ordered[K] {
+	return &Ordered[K]{tree: NewBasic()}
+}
+
+// Insert inserts a key, value pair into the tree.
+func (t *Ordered[K]) Insert(k K, v interface{}) {
+	t.tree.Insert(orderedKey[K]{k}, v)
+}
+
+// Get retrieves the value for a given key, and whether it was present.
+func (t *Ordered[K]) Get(k K) (interface{}, bool) {
+	n := t.tree.Get(orderedKey[K]{k})
+	if n == nil {
+		return nil, false
+	}
+	return n.Value, true
+}
+
+// Delete removes a key from the tree, returning whether it was present.
+func (t *Ordered[K]) Delete(k K) bool {
+	n := t.tree.Get(orderedKey[K]{k})
+	if n == nil {
+		return false
+	}
+	n.Delete()
+	return true
+}
+
+// Len returns the number of keys in the tree.
+func (t *Ordered[K]) Len() int {
+	return t.tree.Len()
+}
+
+// Visit visits the key, value pairs from low to high.
+func (t *Ordered[K]) Visit(f func(k K, v interface{}) error) error {
+	return t.tree.Visit(func(n *BasicBST) error {
+		return f(n.Key.(orderedKey[K]).k, n.Value)
+	})
+}
@@ -0,0 +1,57 @@
+package bst
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestOrderedInts(t *testing.T) {
+	s := NewOrdered[int]()
+	for _, k := range rand.Perm(50) {
+		s.Insert(k, -k)
+	}
+	if got := s.Len(); got != 50 {
+		t.Errorf("bad Len: got %d, want 50", got)
+	}
+	for k := 0; k < 50; k++ {
+		v, ok := s.Get(k)
+		if !ok || v.(int) != -k {
+			t.Errorf("bad Get(%d): got %v, %v", k, v, ok)
+		}
+	}
+	if v, ok := s.Get(50); ok {
+		t.Errorf("unexpected Get(50): %v", v)
+	}
+	if !s.Delete(10) || s.Delete(10) {
+		t.Errorf("bad Delete(10)")
+	}
+	want := 0
+	s.Visit(func(k int, v interface{}) error {
+		if want == 10 {
+			want++
+		}
+		if k != want {
+			t.Errorf("bad key: got %d, want %d", k, want)
+		}
+		want++
+		return nil
+	})
+}
+
+func TestOrderedStrings(t *testing.T) {
+	s := NewOrdered[string]()
+	for _, k := range []string{"pear", "apple", "fig"} {
+		s.Insert(k, len(k))
+	}
+	var got []string
+	s.Visit(func(k string, v interface{}) error {
+		got = append(got, k)
+		return nil
+	})
+	want := []string{"apple", "fig", "pear"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bad key at %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package bst
+
+import (
+	"sync"
+)
+
+// SafeBST is a BasicBST guarded for concurrent use.
+type SafeBST struct {
+	mu   sync.RWMutex
+	tree *BasicBST
+}
+
+// NewSafe allocates a new SafeBST.
+func NewSafe() *SafeBST {
+	return &SafeBST{tree: NewBasic()}
+}
+
+// Insert inserts a key, value pair into the tree.
+func (s *SafeBST) Insert(k KeyType, v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.Insert(k, v)
+}
+
+// Delete removes a key from the tree, returning whether it was present.
+func (s *SafeBST) Delete(k KeyType) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.tree.Get(k)
+	if n == nil {
+		return false
+	}
+	n.Delete()
+	return true
+}
+
+// Lookup returns the value for a given key and whether the key is present.
+func (s *SafeBST) Lookup(k KeyType) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Lookup(k)
+}
+
+// Len returns the number of keys in the tree.
+func (s *SafeBST) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree.Len()
+}
+
+// Snapshot copies the tree's pairs under a brief read lock and returns an
+// iterator over the copy. The snapshot is point-in-time: writes made after
+// it is taken are not seen, and iterating it never blocks writers.
+func (s *SafeBST) Snapshot() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &Snapshot{pairs: s.tree.ToSlice(), i: -1}
+}
+
+// Snapshot iterates over a point-in-time copy of a tree's pairs, from low to
+// high.
+type Snapshot struct {
+	pairs []Pair
+	i     int
+}
+
+// Next advances to the next pair, returning false when there are no more.
+func (s *Snapshot) Next() bool {
+	if s.i < len(s.pairs) {
+		s.i++
+	}
+	return s.i < len(s.pairs)
+}
+
+// Key returns the key of the current pair.
+func (s *Snapshot) Key() KeyType {
+	return s.pairs[s.i].Key
+}
+
+// Value returns the value of the current pair.
+func (s *Snapshot) Value() interface{} {
+	return s.pairs[s.i].Value
+}
+
+// Len returns the number of pairs in the snapshot.
+func (s *Snapshot) Len() int {
+	return len(s.pairs)
+}
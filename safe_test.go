@@ -0,0 +1,40 @@
+package bst
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	s := NewSafe()
+	for k := 0; k < 100; k++ {
+		s.Insert(iKey(k), -k)
+	}
+	snap := s.Snapshot()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := 0; k < 100; k++ {
+			s.Delete(iKey(k))
+			s.Insert(iKey(k+100), k)
+		}
+	}()
+	want := 0
+	for snap.Next() {
+		if got := int(snap.Key().(iKey)); got != want {
+			t.Errorf("bad snapshot key: got %d, want %d", got, want)
+		}
+		if got := snap.Value().(int); got != -want {
+			t.Errorf("bad snapshot value at %d: got %d", want, got)
+		}
+		want++
+	}
+	wg.Wait()
+	if want != 100 || snap.Len() != 100 {
+		t.Errorf("bad snapshot length: got %d, want 100", want)
+	}
+	if _, ok := s.Lookup(iKey(0)); ok || s.Len() != 100 {
+		t.Errorf("writer changes missing from tree")
+	}
+}
@@ -0,0 +1,28 @@
+package bst
+
+import "math"
+
+// scapegoat restores, in a tree made by NewBasicScapegoat, the depth bound
+// broken by inserting the leaf n: if n lies deeper than log base 1/alpha of
+// the tree's size, it rebuilds the subtree of the lowest ancestor whose child
+// on n's side holds more than alpha of its nodes. Such a scapegoat always
+// exists while the bound is broken.
+func (n *BasicBST) scapegoat() {
+	if n.info == nil || n.info.alpha == 0 {
+		return
+	}
+	alpha := n.info.alpha
+	depth, t := 0, n
+	for ; !t.IsSentinel(); t = t.Parent {
+		depth++
+	}
+	if float64(depth-1) <= math.Log(float64(t.count()))/math.Log(1/alpha) {
+		return
+	}
+	for c := n; !c.Parent.IsSentinel(); c = c.Parent {
+		if float64(c.count()) > alpha*float64(c.Parent.count()) {
+			c.Parent.RebalanceNodes()
+			return
+		}
+	}
+}
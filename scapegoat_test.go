@@ -0,0 +1,40 @@
+package bst
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScapegoat(t *testing.T) {
+	const size, alpha = 10000, 0.7
+	s := NewBasicScapegoat(alpha)
+	for k := 0; k < size; k++ {
+		s.Insert(iKey(k), -k)
+	}
+	bound := int(math.Log(size)/math.Log(1/alpha)) + 1
+	if h := s.Height(); h > bound {
+		t.Errorf("bad height: got %d, want at most %d", h, bound)
+	}
+	if s.Len() != size {
+		t.Errorf("bad Len: got %d, want %d", s.Len(), size)
+	}
+	if err := s.SelfTest(); err != nil {
+		t.Errorf("bad tree: %v", err)
+	}
+	g := NewBasicScapegoat(alpha)
+	for k := 0; k < size; k++ {
+		g.GetOrInsert(iKey(k), -k)
+	}
+	if h := g.Height(); h > bound {
+		t.Errorf("bad height after GetOrInsert: got %d, want at most %d", h, bound)
+	}
+	if err := g.SelfTest(); err != nil {
+		t.Errorf("bad tree after GetOrInsert: %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("missing panic for alpha 1")
+		}
+	}()
+	NewBasicScapegoat(1)
+}
@@ -0,0 +1,82 @@
+package bst
+
+import "fmt"
+
+// SelfTest checks every invariant the tree maintains, returning an error
+// naming the first offending key, or nil: that the links are acyclic and
+// every Parent points back to the node linking to it, that the keys are in
+// order within the bounds set by their ancestors, and that every stored
+// subtree size is correct. It is meant to be run after each operation in
+// fuzz tests.
+func (n *BasicBST) SelfTest() error {
+	for !n.IsSentinel() {
+		if n.Parent == nil {
+			return fmt.Errorf("node %s: not linked into a tree", n.Key)
+		}
+		n = n.Parent
+	}
+	if err := n.VerifyAcyclic(); err != nil {
+		return err
+	}
+	if err := n.Validate(); err != nil {
+		return err
+	}
+	return n.Visit(func(n *BasicBST) error {
+		if size := 1 + n.Child[lo].count() + n.Child[hi].count(); n.size != size {
+			return fmt.Errorf("node %s: stored size %d, want %d", n.Key, n.size, size)
+		}
+		return nil
+	})
+}
+
+// SelfTest checks every invariant the tree maintains, returning an error
+// naming the first offending key, or nil: that the links are acyclic and
+// every Parent points back to the node linking to it, that the keys are in
+// order within the bounds set by their ancestors, and that every node is
+// balanced and stores its correct height.
+func (n *AVL) SelfTest() error {
+	for !n.IsSentinel() {
+		if n.Parent == nil {
+			return fmt.Errorf("node %s: not linked into a tree", n.Key)
+		}
+		n = n.Parent
+	}
+	if err := n.VerifyAcyclic(); err != nil {
+		return err
+	}
+	return n.Validate()
+}
+
+// VerifyAcyclic checks the links below n without recursing into a cycle. It
+// returns an error describing the first node reached twice, or whose Parent
+// does not point back to the node linking to it, or nil if there is none.
+func (n *AVL) VerifyAcyclic() error {
+	seen := map[*AVL]bool{n: true}
+	stack := []*AVL{n}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for d, c := range p.Child {
+			if c == nil || (d == hi && p.IsSentinel()) {
+				continue
+			}
+			if seen[c] {
+				return fmt.Errorf("node %s: reached twice, the links form a cycle", c.name())
+			}
+			if c.Parent != p {
+				return fmt.Errorf("node %s: Parent does not point back to %s", c.Key, p.name())
+			}
+			seen[c] = true
+			stack = append(stack, c)
+		}
+	}
+	return nil
+}
+
+// name returns n's key as a string, or "sentinel".
+func (n *AVL) name() string {
+	if n.IsSentinel() {
+		return "sentinel"
+	}
+	return n.Key.String()
+}
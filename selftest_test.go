@@ -0,0 +1,56 @@
+package bst
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSelfTestFuzz(t *testing.T) {
+	const ops, keys = 10000, 500
+	b, a := NewBasic(), NewAVL()
+	for i := 0; i < ops; i++ {
+		k := iKey(rand.Intn(keys))
+		if rand.Intn(3) == 0 {
+			b.Get(k).Delete()
+			a.Get(k).Delete()
+		} else {
+			b.Insert(k, -k)
+			a.Insert(k, -k)
+		}
+		if err := b.SelfTest(); err != nil {
+			t.Fatalf("BasicBST op %d on %d: %v", i, k, err)
+		}
+		if err := a.SelfTest(); err != nil {
+			t.Fatalf("AVL op %d on %d: %v", i, k, err)
+		}
+	}
+}
+
+func TestSelfTestFailures(t *testing.T) {
+	t.Run("size", func(t *testing.T) {
+		s := newSeq(20)
+		s.Get(iKey(7)).size++
+		if err := s.SelfTest(); err == nil {
+			t.Errorf("missing error for a stale size")
+		}
+	})
+	t.Run("cycle", func(t *testing.T) {
+		s := newSeq(20)
+		s.Rebalance()
+		n := s.Select(0)
+		n.Child[lo] = s.Child[lo]
+		if err := n.SelfTest(); err == nil {
+			t.Errorf("missing error for a cycle")
+		}
+	})
+	t.Run("height", func(t *testing.T) {
+		s := NewAVL()
+		for k := 0; k < 20; k++ {
+			s.Insert(iKey(k), -k)
+		}
+		s.Get(iKey(0)).Height = 3
+		if err := s.SelfTest(); err == nil {
+			t.Errorf("missing error for a stale height")
+		}
+	})
+}
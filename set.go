@@ -0,0 +1,102 @@
+package bst
+
+// Set is a sorted set of keys kept in a BasicBST, ignoring values.
+type Set struct {
+	tree *BasicBST
+}
+
+// NewSet allocates a new Set holding keys.
+func NewSet(keys ...KeyType) *Set {
+	s := &Set{tree: NewBasic()}
+	for _, k := range keys {
+		s.Add(k)
+	}
+	return s
+}
+
+// Add adds k to the set.
+func (s *Set) Add(k KeyType) {
+	s.tree.GetOrInsert(k, nil)
+}
+
+// Remove removes k from the set, returning whether it was present.
+func (s *Set) Remove(k KeyType) bool {
+	n := s.tree.Get(k)
+	if n == nil {
+		return false
+	}
+	n.Delete()
+	return true
+}
+
+// Contains reports whether k is in the set.
+func (s *Set) Contains(k KeyType) bool {
+	return s.tree.Get(k) != nil
+}
+
+// Len returns the number of keys in the set.
+func (s *Set) Len() int {
+	return s.tree.Len()
+}
+
+// Visit visits the keys from low to high.
+func (s *Set) Visit(f func(k KeyType) error) error {
+	return s.tree.Visit(func(n *BasicBST) error {
+		return f(n.Key)
+	})
+}
+
+// Union returns a new set of the keys in s or t.
+func (s *Set) Union(t *Set) *Set {
+	return s.merge(t, true, true, true)
+}
+
+// Intersection returns a new set of the keys in both s and t.
+func (s *Set) Intersection(t *Set) *Set {
+	return s.merge(t, false, true, false)
+}
+
+// Difference returns a new set of the keys in s but not in t.
+func (s *Set) Difference(t *Set) *Set {
+	return s.merge(t, true, false, false)
+}
+
+// merge walks s and t together in key order, in linear time, and returns a
+// balanced set of the keys found only in s, in both, or only in t, as
+// selected by the flags. The result's nodes keep the versions of the ones they
+// were copied from, taking s's where a key is in both.
+func (s *Set) merge(t *Set, onlyS, both, onlyT bool) *Set {
+	var nodes []*BasicBST
+	keep := func(n *BasicBST, ok bool) {
+		if ok {
+			nodes = append(nodes, n)
+		}
+	}
+	a, b := s.tree.Select(0), t.tree.Select(0)
+	for a != nil && b != nil {
+		switch {
+		case a.Key.Less(b.Key):
+			keep(a, onlyS)
+			a = a.Next()
+		case b.Key.Less(a.Key):
+			keep(b, onlyT)
+			b = b.Next()
+		default:
+			keep(a, both)
+			a, b = a.Next(), b.Next()
+		}
+	}
+	for ; a != nil; a = a.Next() {
+		keep(a, onlyS)
+	}
+	for ; b != nil; b = b.Next() {
+		keep(b, onlyT)
+	}
+	u := &Set{tree: NewBasic()}
+	u.tree.info.ver = s.tree.Version()
+	if v := t.tree.Version(); v > u.tree.info.ver {
+		u.tree.info.ver = v
+	}
+	u.tree.Child[lo] = cloneBasic(nodes, u.tree)
+	return u
+}
@@ -0,0 +1,56 @@
+package bst
+
+import "testing"
+
+// setKeys returns the keys of s from low to high.
+func setKeys(s *Set) []int {
+	var keys []int
+	s.Visit(func(k KeyType) error {
+		keys = append(keys, int(k.(iKey)))
+		return nil
+	})
+	return keys
+}
+
+func TestSet(t *testing.T) {
+	a, b := NewSet(), NewSet()
+	for k := 0; k < 20; k += 2 {
+		a.Add(iKey(k)) // 0, 2, ..., 18
+	}
+	for k := 18; k >= 9; k -= 3 {
+		b.Add(iKey(k)) // 9, 12, 15, 18
+	}
+	a.Add(iKey(4))
+	if a.Len() != 10 || !a.Contains(iKey(4)) || a.Contains(iKey(5)) {
+		t.Errorf("bad set a: %v", setKeys(a))
+	}
+	for _, c := range []struct {
+		name string
+		got  *Set
+		want []int
+	}{
+		{"Union", a.Union(b), []int{0, 2, 4, 6, 8, 9, 10, 12, 14, 15, 16, 18}},
+		{"Intersection", a.Intersection(b), []int{12, 18}},
+		{"Difference", a.Difference(b), []int{0, 2, 4, 6, 8, 10, 14, 16}},
+		{"reverse Difference", b.Difference(a), []int{9, 15}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			got := setKeys(c.got)
+			if len(got) != len(c.want) || c.got.Len() != len(c.want) {
+				t.Fatalf("bad keys: got %v, want %v", got, c.want)
+			}
+			for i := range got {
+				if got[i] != c.want[i] {
+					t.Errorf("bad keys: got %v, want %v", got, c.want)
+					break
+				}
+			}
+			if err := c.got.tree.Validate(); err != nil {
+				t.Errorf("invalid tree: %v", err)
+			}
+		})
+	}
+	if !a.Remove(iKey(4)) || a.Remove(iKey(4)) || a.Contains(iKey(4)) {
+		t.Errorf("bad Remove")
+	}
+}
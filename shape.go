@@ -0,0 +1,118 @@
+package bst
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// SaveShape writes the tree to w in pre-order, marking each absent child,
+// so that LoadShape can rebuild exactly the same shape rather than a balanced
+// tree. Each node is written as a 1 byte and then its key and value,
+// length-prefixed with uvarints as by Encode; each absent child as a 0 byte.
+// Keys must be BinaryKeys and values encoding.BinaryMarshalers.
+func (n *BasicBST) SaveShape(w io.Writer) error {
+	if n.IsSentinel() {
+		n = n.Child[lo]
+	}
+	bw := bufio.NewWriter(w)
+	if err := n.saveShape(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// saveShape writes n's subtree in pre-order, keeping an explicit stack of
+// the subtrees still to write, absent ones included, rather than recursing.
+func (n *BasicBST) saveShape(w *bufio.Writer) error {
+	stack := []*BasicBST{n}
+	for len(stack) > 0 {
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n == nil {
+			w.WriteByte(0)
+			continue
+		}
+		fields, err := marshalPair(n.Key, n.Value)
+		if err != nil {
+			return err
+		}
+		w.WriteByte(1)
+		for _, b := range fields {
+			w.Write(binary.AppendUvarint(nil, uint64(len(b))))
+			w.Write(b)
+		}
+		stack = append(stack, n.Child[hi], n.Child[lo])
+	}
+	return nil
+}
+
+// LoadShape reads a tree written by SaveShape, using key and value to decode
+// each pair's fields, and returns it with the saved shape and Parent links.
+// It fails if the tree read is not a valid BST.
+func LoadShape(r io.Reader, key func([]byte) (KeyType, error), value func([]byte) (interface{}, error)) (*BasicBST, error) {
+	t := NewBasic()
+	if err := t.loadShape(bufio.NewReader(r), key, value); err != nil {
+		return nil, err
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// loadShape reads the tree to hang under the sentinel n. Rather than
+// recursing it keeps a stack of the child slots still to fill, in the order
+// saveShape wrote them, and fixes the sizes once all are read.
+func (n *BasicBST) loadShape(r *bufio.Reader, key func([]byte) (KeyType, error), value func([]byte) (interface{}, error)) error {
+	field := func() ([]byte, error) {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, errTruncated
+		}
+		b, err := io.ReadAll(io.LimitReader(r, int64(size)))
+		if err != nil || uint64(len(b)) != size {
+			return nil, errTruncated
+		}
+		return b, nil
+	}
+	type slot struct {
+		parent *BasicBST
+		d      int
+	}
+	stack := []slot{{n, lo}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch mark, err := r.ReadByte(); {
+		case err != nil:
+			return errTruncated
+		case mark == 0:
+			continue
+		case mark != 1:
+			return fmt.Errorf("bad node marker %d", mark)
+		}
+		kb, err := field()
+		if err != nil {
+			return err
+		}
+		vb, err := field()
+		if err != nil {
+			return err
+		}
+		k, err := key(kb)
+		if err != nil {
+			return err
+		}
+		v, err := value(vb)
+		if err != nil {
+			return err
+		}
+		c := s.parent.leaf(k, v)
+		s.parent.Child[s.d] = c
+		stack = append(stack, slot{c, hi}, slot{c, lo})
+	}
+	n.refresh()
+	return nil
+}
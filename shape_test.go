@@ -0,0 +1,95 @@
+package bst
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// preOrder returns the keys of n's subtree in pre-order, with "." for each
+// absent child.
+func preOrder(n *BasicBST) string {
+	if n == nil {
+		return "."
+	}
+	return fmt.Sprintf("%s(%s %s)", n.Key, preOrder(n.Child[lo]), preOrder(n.Child[hi]))
+}
+
+func TestShape(t *testing.T) {
+	s := NewBasic()
+	for _, k := range [...]int{1, 2, 3, 9, 8, 4, 5, 0, 7} {
+		s.Insert(IntKey(k), IntKey(-k))
+	}
+	var buf bytes.Buffer
+	if err := s.SaveShape(&buf); err != nil {
+		t.Fatalf("SaveShape failed: %v", err)
+	}
+	data := buf.Bytes()
+	value := func(b []byte) (interface{}, error) {
+		return DecodeIntKey(b)
+	}
+	d, err := LoadShape(bytes.NewReader(data), DecodeIntKey, value)
+	if err != nil {
+		t.Fatalf("LoadShape failed: %v", err)
+	}
+	if got, want := preOrder(d.Child[lo]), preOrder(s.Child[lo]); got != want {
+		t.Errorf("bad shape:\ngot  %s\nwant %s", got, want)
+	}
+	if d.Height() != s.Height() || d.Len() != s.Len() {
+		t.Errorf("bad height or Len: got %d/%d, want %d/%d", d.Height(), d.Len(), s.Height(), s.Len())
+	}
+	if err := d.VerifyAcyclic(); err != nil {
+		t.Errorf("bad links: %v", err)
+	}
+	for k := 0; k < 10; k++ {
+		n := d.Get(IntKey(k))
+		if k == 6 {
+			if n != nil {
+				t.Errorf("unexpected key 6")
+			}
+			continue
+		}
+		if n == nil || n.Value != IntKey(-k) || n.IndexOf() != s.Get(IntKey(k)).IndexOf() {
+			t.Errorf("bad node for %d: %v", k, n)
+		}
+	}
+	if _, err := LoadShape(bytes.NewReader(data[:len(data)-1]), DecodeIntKey, value); err == nil {
+		t.Errorf("missing error loading truncated data")
+	}
+	empty := NewBasic()
+	buf.Reset()
+	if err := empty.SaveShape(&buf); err != nil {
+		t.Fatalf("SaveShape of empty tree failed: %v", err)
+	}
+	if d, err := LoadShape(&buf, DecodeIntKey, value); err != nil || d.Len() != 0 {
+		t.Errorf("bad empty round trip: %v", err)
+	}
+}
+
+func TestDeepShape(t *testing.T) {
+	const depth = 1000000
+	s := NewBasic()
+	parent, d := s, lo
+	for k := 0; k < depth; k++ {
+		n := &BasicBST{Key: IntKey(k), Value: IntKey(-k), Parent: parent, info: s.info}
+		parent.Child[d] = n
+		parent, d = n, hi
+	}
+	var buf bytes.Buffer
+	if err := s.SaveShape(&buf); err != nil {
+		t.Fatalf("SaveShape failed: %v", err)
+	}
+	value := func(b []byte) (interface{}, error) {
+		return DecodeIntKey(b)
+	}
+	l, err := LoadShape(&buf, DecodeIntKey, value)
+	if err != nil {
+		t.Fatalf("LoadShape failed: %v", err)
+	}
+	if n := l.Len(); n != depth {
+		t.Errorf("bad Len: got %d, want %d", n, depth)
+	}
+	if h := l.Height(); h != depth-1 {
+		t.Errorf("bad Height: got %d, want %d", h, depth-1)
+	}
+}
@@ -0,0 +1,460 @@
+package bst
+
+import (
+	"context"
+	"fmt"
+)
+
+// none is the slab index standing for no node.
+const none = -1
+
+// slabNode is a node of a SlabBST, linked to others by slab index. A free
+// slot has a nil key and chains to the next free slot through child[lo].
+type slabNode struct {
+	key    KeyType
+	value  interface{}
+	parent int32
+	child  [2]int32 // index is oneof {lo, hi}
+}
+
+// SlabBST is an unbalanced BST whose nodes live together in one slice and
+// link to each other by index rather than by pointer. Searches then walk
+// contiguous memory instead of chasing pointers about the heap, which keeps
+// large trees friendlier to the cache.
+//
+// Its methods mirror BasicBST's, with a *SlabNode standing for a *BasicBST.
+// Delete leaves a hole in the slab for a later Insert to reuse rather than
+// moving other nodes, so a *SlabNode stays valid just as long as the
+// *BasicBST would. What rests on BasicBST's extra per-node state, such as
+// ranks, versions, metadata and tie policies, has no SlabBST counterpart.
+type SlabBST struct {
+	nodes []slabNode
+	root  int32
+	free  int32 // first free slot, or none
+	size  int
+}
+
+// SlabNode is a handle on a node of a SlabBST.
+type SlabNode struct {
+	t *SlabBST
+	i int32
+}
+
+// NewSlab allocates a new SlabBST with room for size nodes before its slab
+// has to grow.
+func NewSlab(size int) *SlabBST {
+	return &SlabBST{nodes: make([]slabNode, 0, size), root: none, free: none}
+}
+
+// node returns a handle on the node at index i, or nil if i is none.
+func (t *SlabBST) node(i int32) *SlabNode {
+	if i == none {
+		return nil
+	}
+	return &SlabNode{t: t, i: i}
+}
+
+// Key returns the node's key, or nil once the node is deleted.
+func (n *SlabNode) Key() KeyType {
+	return n.t.nodes[n.i].key
+}
+
+// Value returns the node's value.
+func (n *SlabNode) Value() interface{} {
+	return n.t.nodes[n.i].value
+}
+
+// SetValue replaces the node's value.
+func (n *SlabNode) SetValue(v interface{}) {
+	n.t.nodes[n.i].value = v
+}
+
+// find returns the index of the node holding k, or none.
+func (t *SlabBST) find(k KeyType) int32 {
+	i := t.root
+	for i != none {
+		n := &t.nodes[i]
+		switch {
+		case k.Less(n.key):
+			i = n.child[lo]
+		case n.key.Less(k):
+			i = n.child[hi]
+		default:
+			return i
+		}
+	}
+	return none
+}
+
+// Get returns the node holding k, or nil if the key is absent.
+func (t *SlabBST) Get(k KeyType) *SlabNode {
+	return t.node(t.find(k))
+}
+
+// Lookup returns the value for a given key and whether the key is present,
+// without exposing the tree's nodes.
+func (t *SlabBST) Lookup(k KeyType) (interface{}, bool) {
+	i := t.find(k)
+	if i == none {
+		return nil, false
+	}
+	return t.nodes[i].value, true
+}
+
+// Insert inserts a key, value pair into the tree, replacing the value of an
+// equal key, and returns the node holding the key.
+func (t *SlabBST) Insert(k KeyType, v interface{}) *SlabNode {
+	n, _ := t.insert(k, v, true)
+	return n
+}
+
+// GetOrInsert returns the node holding k and false if k is present, leaving
+// its value alone. Otherwise it inserts the pair and returns the new node and
+// true.
+func (t *SlabBST) GetOrInsert(k KeyType, v interface{}) (*SlabNode, bool) {
+	return t.insert(k, v, false)
+}
+
+// insert finds or adds the node for k, writing v to an existing one only if
+// overwrite is set, and reports whether it added the node.
+func (t *SlabBST) insert(k KeyType, v interface{}, overwrite bool) (*SlabNode, bool) {
+	p, d := int32(none), lo
+	for i := t.root; i != none; {
+		n := &t.nodes[i]
+		switch {
+		case k.Less(n.key):
+			d = lo
+		case n.key.Less(k):
+			d = hi
+		default:
+			if overwrite {
+				n.value = v
+			}
+			return t.node(i), false
+		}
+		p, i = i, n.child[d]
+	}
+	i := t.alloc(slabNode{key: k, value: v, parent: p, child: [2]int32{none, none}})
+	t.link(p, d, i)
+	return t.node(i), true
+}
+
+// InsertAll inserts each of the key, value pairs into the tree in order, so a
+// later pair overwrites an earlier one with an equal key.
+func (t *SlabBST) InsertAll(pairs []Pair) {
+	for _, p := range pairs {
+		t.Insert(p.Key, p.Value)
+	}
+}
+
+// Update replaces the value for k with f's result and returns true if k is
+// present. Otherwise it returns false and inserts nothing.
+func (t *SlabBST) Update(k KeyType, f func(old interface{}) interface{}) bool {
+	i := t.find(k)
+	if i == none {
+		return false
+	}
+	t.nodes[i].value = f(t.nodes[i].value)
+	return true
+}
+
+// alloc stores n in a free slot, or at the end of the slab if there is
+// none, and returns its index.
+func (t *SlabBST) alloc(n slabNode) int32 {
+	t.size++
+	if i := t.free; i != none {
+		t.free = t.nodes[i].child[lo]
+		t.nodes[i] = n
+		return i
+	}
+	t.nodes = append(t.nodes, n)
+	return int32(len(t.nodes) - 1)
+}
+
+// release frees the unlinked slot i for reuse.
+func (t *SlabBST) release(i int32) {
+	t.nodes[i] = slabNode{parent: none, child: [2]int32{t.free, none}}
+	t.free = i
+	t.size--
+}
+
+// link makes c the child of p on side d, or the root if p is none.
+func (t *SlabBST) link(p int32, d int, c int32) {
+	if p == none {
+		t.root = c
+	} else {
+		t.nodes[p].child[d] = c
+	}
+	if c != none {
+		t.nodes[c].parent = p
+	}
+}
+
+// side returns which child of its parent node i is.
+func (t *SlabBST) side(i int32) int {
+	if p := t.nodes[i].parent; p != none && t.nodes[p].child[hi] == i {
+		return hi
+	}
+	return lo
+}
+
+// Delete removes the node from its tree. As with BasicBST, a node with two
+// children takes its successor's pair, and it is the successor's node that
+// goes.
+func (n *SlabNode) Delete() {
+	if n == nil || n.Key() == nil {
+		return
+	}
+	t, i := n.t, n.i
+	if x := &t.nodes[i]; x.child[lo] != none && x.child[hi] != none {
+		s := x.child[hi]
+		for t.nodes[s].child[lo] != none {
+			s = t.nodes[s].child[lo]
+		}
+		x.key, x.value = t.nodes[s].key, t.nodes[s].value
+		i = s
+	}
+	x := &t.nodes[i]
+	c := x.child[lo]
+	if c == none {
+		c = x.child[hi]
+	}
+	t.link(x.parent, t.side(i), c)
+	t.release(i)
+}
+
+// Next returns the next node, or nil at the end of the tree.
+func (n *SlabNode) Next() *SlabNode {
+	return n.next(hi)
+}
+
+// Prev returns the previous node, or nil at the start of the tree.
+func (n *SlabNode) Prev() *SlabNode {
+	return n.next(lo)
+}
+
+// next returns the neighbouring node in the given direction.
+func (n *SlabNode) next(d int) *SlabNode {
+	if n == nil || n.Key() == nil {
+		return nil
+	}
+	t, i, r := n.t, n.i, opposite(d)
+	if c := t.nodes[i].child[d]; c != none {
+		for t.nodes[c].child[r] != none {
+			c = t.nodes[c].child[r]
+		}
+		return t.node(c)
+	}
+	for p := t.nodes[i].parent; p != none; i, p = p, t.nodes[p].parent {
+		if t.nodes[p].child[r] == i {
+			return t.node(p)
+		}
+	}
+	return nil
+}
+
+// Floor returns the last node whose key is not greater than k, or nil.
+func (t *SlabBST) Floor(k KeyType) *SlabNode {
+	return t.node(t.near(k, lo))
+}
+
+// Ceil returns the first node whose key is not less than k, or nil.
+func (t *SlabBST) Ceil(k KeyType) *SlabNode {
+	return t.node(t.near(k, hi))
+}
+
+// near returns the index of the node holding k, or else of the nearest one
+// on side d of it, or none.
+func (t *SlabBST) near(k KeyType, d int) int32 {
+	best := int32(none)
+	for i := t.root; i != none; {
+		n := &t.nodes[i]
+		switch {
+		case k.Less(n.key):
+			if d == hi {
+				best = i
+			}
+			i = n.child[lo]
+		case n.key.Less(k):
+			if d == lo {
+				best = i
+			}
+			i = n.child[hi]
+		default:
+			return i
+		}
+	}
+	return best
+}
+
+// PopMin removes the lowest key from the tree and returns it with its value,
+// or returns false if the tree is empty.
+func (t *SlabBST) PopMin() (KeyType, interface{}, bool) {
+	return t.pop(lo)
+}
+
+// PopMax removes the highest key from the tree and returns it with its value,
+// or returns false if the tree is empty.
+func (t *SlabBST) PopMax() (KeyType, interface{}, bool) {
+	return t.pop(hi)
+}
+
+// pop removes the tree's outermost node on side d and returns its pair.
+func (t *SlabBST) pop(d int) (KeyType, interface{}, bool) {
+	i := t.root
+	if i == none {
+		return nil, nil, false
+	}
+	for t.nodes[i].child[d] != none {
+		i = t.nodes[i].child[d]
+	}
+	k, v := t.nodes[i].key, t.nodes[i].value
+	t.node(i).Delete()
+	return k, v, true
+}
+
+// Len returns the number of keys in the tree.
+func (t *SlabBST) Len() int {
+	return t.size
+}
+
+// Height returns the height of the tree, or -1 if it is empty.
+func (t *SlabBST) Height() int {
+	type entry struct {
+		i     int32
+		depth int
+	}
+	h := -1
+	if t.root == none {
+		return h
+	}
+	stack := []entry{{t.root, 0}}
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if e.depth > h {
+			h = e.depth
+		}
+		for _, c := range t.nodes[e.i].child {
+			if c != none {
+				stack = append(stack, entry{c, e.depth + 1})
+			}
+		}
+	}
+	return h
+}
+
+// Visit visits the nodes from low to high, stopping at and returning the
+// first error from f.
+func (t *SlabBST) Visit(f func(n *SlabNode) error) error {
+	var stack []int32
+	i := t.root
+	for i != none || len(stack) > 0 {
+		for ; i != none; i = t.nodes[i].child[lo] {
+			stack = append(stack, i)
+		}
+		i = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		next := t.nodes[i].child[hi]
+		if err := f(t.node(i)); err != nil {
+			return err
+		}
+		i = next
+	}
+	return nil
+}
+
+// RangeVisit visits in tree order the nodes whose keys lie in the closed
+// range [from, to], skipping the subtrees outside it.
+func (t *SlabBST) RangeVisit(from, to KeyType, f func(n *SlabNode) error) error {
+	var stack []int32
+	i := t.root
+	for i != none || len(stack) > 0 {
+		for i != none {
+			if t.nodes[i].key.Less(from) {
+				i = t.nodes[i].child[hi]
+				continue
+			}
+			stack = append(stack, i)
+			i = t.nodes[i].child[lo]
+		}
+		i = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if to.Less(t.nodes[i].key) {
+			return nil
+		}
+		next := t.nodes[i].child[hi]
+		if err := f(t.node(i)); err != nil {
+			return err
+		}
+		i = next
+	}
+	return nil
+}
+
+// Keys returns a channel to stream the keys from low to high.
+func (t *SlabBST) Keys(ctx context.Context) chan KeyType {
+	keys := make(chan KeyType)
+	go func() {
+		defer close(keys)
+		t.Visit(func(n *SlabNode) error {
+			select {
+			case keys <- n.Key():
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return keys
+}
+
+// ToSlice returns the key, value pairs of the tree from low to high.
+func (t *SlabBST) ToSlice() []Pair {
+	pairs := make([]Pair, 0, t.Len())
+	t.Visit(func(n *SlabNode) error {
+		pairs = append(pairs, Pair{Key: n.Key(), Value: n.Value()})
+		return nil
+	})
+	return pairs
+}
+
+// Validate returns an error describing the first node found violating the
+// BST condition or holding a stale parent index, or nil if the tree is valid.
+func (t *SlabBST) Validate() error {
+	type entry struct {
+		i            int32
+		below, above KeyType
+	}
+	if t.root != none && t.nodes[t.root].parent != none {
+		return fmt.Errorf("node %s: root has parent %d", t.nodes[t.root].key, t.nodes[t.root].parent)
+	}
+	seen := 0
+	stack := []entry{{i: t.root}}
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if e.i == none {
+			continue
+		}
+		if seen++; seen > t.size {
+			return fmt.Errorf("more nodes linked than the %d counted", t.size)
+		}
+		n := &t.nodes[e.i]
+		if err := boundsError(n.key, e.below, e.above); err != nil {
+			return err
+		}
+		for _, c := range n.child {
+			if c != none && t.nodes[c].parent != e.i {
+				return fmt.Errorf("node %s: parent %d, want %d", t.nodes[c].key, t.nodes[c].parent, e.i)
+			}
+		}
+		stack = append(stack,
+			entry{n.child[lo], e.below, n.key},
+			entry{n.child[hi], n.key, e.above})
+	}
+	if seen != t.size {
+		return fmt.Errorf("%d nodes linked, want %d", seen, t.size)
+	}
+	return nil
+}
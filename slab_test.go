@@ -0,0 +1,155 @@
+package bst
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestSlab(t *testing.T) {
+	const size = 10000
+	s := NewSlab(0)
+	for _, p := range shuffledPairs(size) {
+		s.Insert(p.Key, p.Value)
+	}
+	if s.Len() != size {
+		t.Errorf("bad Len: got %d, want %d", s.Len(), size)
+	}
+	for k := 0; k < size; k++ {
+		if v, ok := s.Lookup(iKey(k)); !ok || v != -k {
+			t.Errorf("bad Lookup(%d): got %v/%v", k, v, ok)
+		}
+	}
+	deleted := map[int]bool{}
+	for _, k := range rand.Perm(size)[:size/2] {
+		n := s.Get(iKey(k))
+		if n == nil {
+			t.Errorf("missing key to delete: %d", k)
+			continue
+		}
+		n.Delete()
+		deleted[k] = true
+	}
+	if s.Get(iKey(size)) != nil {
+		t.Errorf("found a missing key")
+	}
+	if s.Len() != size/2 {
+		t.Errorf("bad Len after delete: got %d, want %d", s.Len(), size/2)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid tree after delete: %v", err)
+	}
+	for k := 0; k < size; k++ {
+		if _, ok := s.Lookup(iKey(k)); ok == deleted[k] {
+			t.Errorf("bad presence of %d: got %v", k, ok)
+		}
+	}
+	last := -1
+	s.Visit(func(n *SlabNode) error {
+		if got := int(n.Key().(iKey)); got <= last || deleted[got] || n.Value() != -got {
+			t.Errorf("bad pair after %d: %d/%v", last, got, n.Value())
+		}
+		last = int(n.Key().(iKey))
+		return nil
+	})
+	before := len(s.nodes)
+	for k := range deleted {
+		s.Insert(iKey(k), -k)
+	}
+	if len(s.nodes) != before {
+		t.Errorf("slab grew from %d to %d reinserting deleted keys", before, len(s.nodes))
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid tree after reinserting: %v", err)
+	}
+}
+
+func TestSlabNodes(t *testing.T) {
+	s := NewSlab(0)
+	if _, _, ok := s.PopMin(); ok {
+		t.Errorf("unexpected PopMin of empty tree")
+	}
+	if h := s.Height(); h != -1 {
+		t.Errorf("bad empty height: got %d, want -1", h)
+	}
+	for _, p := range shuffledPairs(100) {
+		if k := p.Key.(iKey); k%2 == 0 {
+			s.Insert(k, p.Value)
+		}
+	}
+	ten := s.Get(iKey(10))
+	for _, k := range [...]int{30, 50, 70} {
+		s.Get(iKey(k)).Delete()
+	}
+	if ten.Key() != iKey(10) || ten.Value() != -10 {
+		t.Errorf("handle moved: got %v/%v", ten.Key(), ten.Value())
+	}
+	if n := ten.Next(); n == nil || n.Key() != iKey(12) {
+		t.Errorf("bad Next of 10: %v", n)
+	}
+	if n := ten.Prev(); n == nil || n.Key() != iKey(8) {
+		t.Errorf("bad Prev of 10: %v", n)
+	}
+	if n := s.Get(iKey(98)).Next(); n != nil {
+		t.Errorf("unexpected Next of the last node: %v", n.Key())
+	}
+	if n := s.Floor(iKey(31)); n == nil || n.Key() != iKey(28) {
+		t.Errorf("bad Floor(31): %v", n)
+	}
+	if n := s.Ceil(iKey(49)); n == nil || n.Key() != iKey(52) {
+		t.Errorf("bad Ceil(49): %v", n)
+	}
+	if n, ok := s.GetOrInsert(iKey(10), 0); ok || n.Value() != -10 {
+		t.Errorf("GetOrInsert did not find 10")
+	}
+	if !s.Update(iKey(12), func(old interface{}) interface{} { return 12 }) {
+		t.Errorf("Update missed 12")
+	}
+	var got []int
+	s.RangeVisit(iKey(25), iKey(34), func(n *SlabNode) error {
+		got = append(got, int(n.Key().(iKey)))
+		return nil
+	})
+	if want := "[26 28 32 34]"; fmt.Sprint(got) != want {
+		t.Errorf("bad range: got %v, want %s", got, want)
+	}
+	n := 0
+	for range s.Keys(context.Background()) {
+		n++
+	}
+	if n != 47 || len(s.ToSlice()) != 47 {
+		t.Errorf("bad key count: got %d, want 47", n)
+	}
+	if k, v, ok := s.PopMax(); !ok || k != iKey(98) || v != -98 {
+		t.Errorf("bad PopMax: got %v/%v/%v", k, v, ok)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("invalid tree: %v", err)
+	}
+	s.nodes[s.find(iKey(0))].key = iKey(1001)
+	if s.Validate() == nil {
+		t.Errorf("missed an out of order key")
+	}
+}
+
+func BenchmarkSlabGet(b *testing.B) {
+	const size = 1000000
+	pairs := shuffledPairs(size)
+	b.Run("pointer", func(b *testing.B) {
+		s := NewBasic()
+		s.InsertAll(pairs)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.Lookup(pairs[i%size].Key)
+		}
+	})
+	b.Run("slab", func(b *testing.B) {
+		s := NewSlab(size)
+		s.InsertAll(pairs)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			s.Lookup(pairs[i%size].Key)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package bst
+
+// smallMax is the size below which a tree made by NewBasicSmallOpt searches
+// a sorted slice of its nodes rather than descending the tree.
+const smallMax = 8
+
+// NewBasicSmallOpt allocates a new BasicBST whose Get, while the tree holds
+// fewer than smallMax keys, scans a cached sorted slice of its nodes instead
+// of descending the tree, which is faster for tiny trees. The slice is
+// rebuilt lazily after inserts and deletes, so unlike other trees such a tree
+// must not be read by concurrent Gets.
+func NewBasicSmallOpt() *BasicBST {
+	sentinel := NewBasic()
+	sentinel.info.small = true
+	return sentinel
+}
+
+// invalidate marks the tree's cached node slice as stale.
+func (t *treeInfo) invalidate() {
+	if t != nil {
+		t.cacheOf = nil
+	}
+}
+
+// smallGet looks k up by scanning the node slice cached for the sentinel n,
+// returning false if the tree is not small enough to use it.
+func (n *BasicBST) smallGet(k KeyType) (*BasicBST, bool) {
+	t := n.info
+	if t == nil || !t.small || n.count() >= smallMax {
+		return nil, false
+	}
+	if t.cacheOf != n {
+		t.cache = t.cache[:0]
+		n.Visit(func(n *BasicBST) error {
+			t.cache = append(t.cache, n)
+			return nil
+		})
+		t.cacheOf = n
+	}
+	// Count the comparisons once at the end rather than one by one, lest
+	// the counting cost more than the comparing.
+	var f *BasicBST
+	cmps := uint64(0)
+	for _, c := range t.cache {
+		cmps++
+		if !c.Key.Less(k) {
+			cmps++
+			if !k.Less(c.Key) {
+				f = c
+			}
+			break
+		}
+	}
+	t.comparedN(cmps)
+	return f, true
+}
@@ -0,0 +1,54 @@
+package bst
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSmallOpt(t *testing.T) {
+	s, ref := NewBasicSmallOpt(), NewBasic()
+	check := func(t *testing.T) {
+		for k := -1; k <= 20; k++ {
+			got, want := s.Get(iKey(k)), ref.Get(iKey(k))
+			if (got == nil) != (want == nil) || got != nil && (got.Key != want.Key || got.Value != want.Value) {
+				t.Errorf("bad Get(%d): got %v, want %v", k, got, want)
+			}
+		}
+	}
+	for i, k := range rand.Perm(20) {
+		s.Insert(iKey(k), -k)
+		ref.Insert(iKey(k), -k)
+		if i%3 == 0 {
+			d := rand.Intn(20)
+			s.Get(iKey(d)).Delete()
+			ref.Get(iKey(d)).Delete()
+		}
+		check(t)
+	}
+	for k := 0; k < 20; k++ {
+		s.Get(iKey(k)).Delete()
+		ref.Get(iKey(k)).Delete()
+		check(t)
+	}
+	s.Insert(iKey(3), -3)
+	s.Clear()
+	if s.Get(iKey(3)) != nil {
+		t.Errorf("unexpected key after Clear")
+	}
+}
+
+func BenchmarkSmallGet(b *testing.B) {
+	for _, c := range []struct {
+		name string
+		tree *BasicBST
+	}{{"tree", NewBasic()}, {"slice", NewBasicSmallOpt()}} {
+		for _, k := range [...]int{2, 1, 3, 4} {
+			c.tree.Insert(iKey(k), -k)
+		}
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c.tree.Get(iKey(i % 5))
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package bst
+
+import (
+	"sync/atomic"
+)
+
+// Stats counts the work done by a tree.
+type Stats struct {
+	Comparisons uint64 // key comparisons made by Get, Insert, Rank and CountRange
+	Rotations   uint64 // rotations made while balancing
+}
+
+// treeInfo holds the state shared by all the nodes of one tree.
+type treeInfo struct {
+	stats  Stats
+	basics []BasicBST // node arena for a BasicBST
+	avls   []AVL      // node arena for an AVL
+	used   int        // number of arena nodes handed out
+	tie    TiePolicy
+	strict bool    // reject nil values
+	ver    uint64  // number of value writes so far
+	ids    uint64  // number of node IDs handed out
+	alpha  float64 // scapegoat balance factor, or 0 for none
+	small  bool    // let Get scan a cached node slice in tiny trees
+
+	// sorted nodes of the tree whose sentinel is cacheOf, or stale if nil
+	cache   []*BasicBST
+	cacheOf *BasicBST
+
+	// subtree aggregation, enabled when combine is set
+	zero    interface{}
+	extract func(v interface{}) interface{}
+	combine func(a, b interface{}) interface{}
+}
+
+func (t *treeInfo) allocBasic() *BasicBST {
+	if t != nil && t.used < len(t.basics) {
+		t.used++
+		return &t.basics[t.used-1]
+	}
+	return &BasicBST{}
+}
+
+func (t *treeInfo) allocAVL() *AVL {
+	if t != nil && t.used < len(t.avls) {
+		t.used++
+		return &t.avls[t.used-1]
+	}
+	return &AVL{}
+}
+
+// recycle makes the whole arena available again, dropping the references
+// its nodes held.
+func (t *treeInfo) recycle() {
+	if t == nil {
+		return
+	}
+	for i := range t.basics[:imin(t.used, len(t.basics))] {
+		t.basics[i] = BasicBST{}
+	}
+	for i := range t.avls[:imin(t.used, len(t.avls))] {
+		t.avls[i] = AVL{}
+	}
+	t.used = 0
+}
+
+func (t *treeInfo) compared() {
+	if t != nil {
+		atomic.AddUint64(&t.stats.Comparisons, 1)
+	}
+}
+
+func (t *treeInfo) comparedN(n uint64) {
+	if t != nil {
+		atomic.AddUint64(&t.stats.Comparisons, n)
+	}
+}
+
+func (t *treeInfo) rotated() {
+	if t != nil {
+		atomic.AddUint64(&t.stats.Rotations, 1)
+	}
+}
+
+func (t *treeInfo) snapshot() Stats {
+	if t == nil {
+		return Stats{}
+	}
+	return Stats{
+		Comparisons: atomic.LoadUint64(&t.stats.Comparisons),
+		Rotations:   atomic.LoadUint64(&t.stats.Rotations),
+	}
+}
+
+func (t *treeInfo) reset() {
+	if t != nil {
+		atomic.StoreUint64(&t.stats.Comparisons, 0)
+		atomic.StoreUint64(&t.stats.Rotations, 0)
+	}
+}
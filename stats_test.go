@@ -0,0 +1,27 @@
+package bst
+
+import (
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	a, b := NewAVL(), NewBasic()
+	for k := 0; k < 100; k++ {
+		a.Insert(iKey(k), -k)
+		b.Insert(iKey(k), -k)
+	}
+	if got := a.Stats(); got.Rotations == 0 || got.Comparisons == 0 {
+		t.Errorf("bad AVL stats: %+v", got)
+	}
+	if got := b.Stats(); got.Rotations != 0 || got.Comparisons == 0 {
+		t.Errorf("bad BasicBST stats: %+v", got)
+	}
+	b.ResetStats()
+	if got := b.Stats(); got != (Stats{}) {
+		t.Errorf("bad stats after reset: %+v", got)
+	}
+	b.Get(iKey(0))
+	if got := b.Stats().Comparisons; got == 0 {
+		t.Errorf("Get counted no comparisons")
+	}
+}
@@ -0,0 +1,72 @@
+package bst
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// StreamBuild builds a balanced BasicBST from r, which must hold one pair
+// per line in strictly increasing key order, using parse to decode each
+// line. It links each node into place as it is read rather than collecting
+// the pairs first, so it needs memory only for the tree itself. It fails on
+// the first line parse rejects or that is out of order.
+func StreamBuild(r io.Reader, parse func([]byte) (KeyType, interface{}, error)) (*BasicBST, error) {
+	t := NewBasic()
+	// The i-th node read (from 1) belongs at the level given by the
+	// trailing zeros of i, as in a perfect tree. last holds the most
+	// recent node read at each level, and linked which of those already
+	// hang below another node.
+	var last [64]*BasicBST
+	var linked [64]bool
+	var prev KeyType
+	s := bufio.NewScanner(r)
+	for i := uint(1); s.Scan(); i++ {
+		k, v, err := parse(s.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i, err)
+		}
+		if prev != nil && !prev.Less(k) {
+			return nil, fmt.Errorf("line %d: key %s out of order after %s", i, k, prev)
+		}
+		prev = k
+		x := t.leaf(k, v)
+		h := bits.TrailingZeros(i)
+		if h > 0 {
+			x.Child[lo], last[h-1].Parent, linked[h-1] = last[h-1], x, true
+		}
+		// If i - 2^h sits a level up, x is its hi child; otherwise x
+		// will be the lo child of i + 2^h, if it is ever read.
+		if i&(1<<(h+1)) != 0 {
+			last[h+1].Child[hi], x.Parent = x, last[h+1]
+			linked[h] = true
+		} else {
+			linked[h] = false
+		}
+		last[h] = x
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	// The nodes left unlinked, from the highest level down, each go at the
+	// end of the hi spine below the one before.
+	var end *BasicBST
+	for h := len(last) - 1; h >= 0; h-- {
+		x := last[h]
+		if x == nil || linked[h] {
+			continue
+		}
+		if end == nil {
+			t.Child[lo] = x
+		} else {
+			for end.Child[hi] != nil {
+				end = end.Child[hi]
+			}
+			end.Child[hi], x.Parent = x, end
+		}
+		end = x
+	}
+	t.Child[lo].refresh()
+	return t, nil
+}
@@ -0,0 +1,72 @@
+package bst
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parseLine parses a line holding an int key.
+func parseLine(b []byte) (KeyType, interface{}, error) {
+	k, err := strconv.Atoi(string(b))
+	return iKey(k), -k, err
+}
+
+// sortedLines returns the keys 0..size-1, one per line.
+func sortedLines(size int) string {
+	var b strings.Builder
+	for k := 0; k < size; k++ {
+		fmt.Fprintln(&b, k)
+	}
+	return b.String()
+}
+
+func TestStreamBuild(t *testing.T) {
+	for size := 0; size < 300; size++ {
+		s, err := StreamBuild(strings.NewReader(sortedLines(size)), parseLine)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if err := s.SelfTest(); err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if s.Len() != size {
+			t.Errorf("size %d: bad Len %d", size, s.Len())
+		}
+		if bound := 2 * bits.Len(uint(size)); s.Height() > bound {
+			t.Errorf("size %d: bad height %d, want at most %d", size, s.Height(), bound)
+		}
+	}
+	const size = 100000
+	s, err := StreamBuild(strings.NewReader(sortedLines(size)), parseLine)
+	if err != nil {
+		t.Fatalf("StreamBuild failed: %v", err)
+	}
+	if bound := 2 * bits.Len(size); s.Height() > bound {
+		t.Errorf("bad height: got %d, want at most %d", s.Height(), bound)
+	}
+	for i, p := range s.ToSlice() {
+		if p.Key != iKey(i) || p.Value != -i {
+			t.Fatalf("bad pair at %d: %+v", i, p)
+		}
+	}
+	if err := s.SelfTest(); err != nil {
+		t.Errorf("bad tree: %v", err)
+	}
+}
+
+func TestStreamBuildErrors(t *testing.T) {
+	if _, err := StreamBuild(strings.NewReader("1\n3\n2\n"), parseLine); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("bad out-of-order error: %v", err)
+	}
+	if _, err := StreamBuild(strings.NewReader("1\n1\n"), parseLine); err == nil {
+		t.Errorf("missing error for a repeated key")
+	}
+	var numErr *strconv.NumError
+	if _, err := StreamBuild(strings.NewReader("1\nx\n"), parseLine); !errors.As(err, &numErr) {
+		t.Errorf("bad parse error: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package bst
+
+import (
+	"reflect"
+)
+
+// admit panics if the tree is strict and v is nil or a typed nil.
+func (t *treeInfo) admit(v interface{}) {
+	if t == nil || !t.strict {
+		return
+	}
+	if v == nil {
+		panic("bst: nil value inserted into strict tree")
+	}
+	switch r := reflect.ValueOf(v); r.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Pointer, reflect.Slice:
+		if r.IsNil() {
+			panic("bst: typed nil " + r.Type().String() + " inserted into strict tree")
+		}
+	}
+}
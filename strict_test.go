@@ -0,0 +1,59 @@
+package bst
+
+import (
+	"testing"
+)
+
+func TestStrict(t *testing.T) {
+	var nilPtr *int
+	for _, c := range []struct {
+		name string
+		v    interface{}
+	}{
+		{name: "Nil", v: nil},
+		{name: "TypedNil", v: nilPtr},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := NewBasicStrict()
+			s.Insert(iKey(1), 1)
+			for _, k := range [...]int{1, 2} {
+				func() {
+					defer func() {
+						if recover() == nil {
+							t.Errorf("missing panic inserting %v at %d", c.v, k)
+						}
+					}()
+					s.Insert(iKey(k), c.v)
+				}()
+			}
+			writes := map[string]func(){
+				"Update": func() {
+					s.Update(iKey(1), func(interface{}) interface{} { return c.v })
+				},
+				"PersistentInsert":    func() { s.PersistentInsert(iKey(1), c.v) },
+				"PersistentInsertNew": func() { s.PersistentInsert(iKey(3), c.v) },
+				"MapValues": func() {
+					s.MapValues(func(KeyType, interface{}) interface{} { return c.v })
+				},
+			}
+			for name, write := range writes {
+				func() {
+					defer func() {
+						if recover() == nil {
+							t.Errorf("missing panic writing %v with %s", c.v, name)
+						}
+					}()
+					write()
+				}()
+			}
+			if v, _ := s.Lookup(iKey(1)); v != 1 || s.Len() != 1 {
+				t.Errorf("rejected write changed the tree")
+			}
+			loose := NewBasic()
+			loose.Insert(iKey(1), c.v)
+			if _, ok := loose.Lookup(iKey(1)); !ok {
+				t.Errorf("non-strict tree rejected %v", c.v)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package bst
+
+// TiePolicy chooses what Insert does with a key that is already present.
+type TiePolicy int
+
+const (
+	// Overwrite replaces the existing value. It is the default.
+	Overwrite TiePolicy = iota
+	// KeepFirst keeps the existing value and drops the new one.
+	KeepFirst
+	// Append keeps every value inserted at a key, in insertion order. Each
+	// node's Value is then a []interface{}, even for a single insert.
+	Append
+)
+
+// initial returns the value to store for a newly inserted key.
+func (t *treeInfo) initial(v interface{}) interface{} {
+	t.admit(v)
+	if t != nil && t.tie == Append {
+		return []interface{}{v}
+	}
+	return v
+}
+
+// merge returns the value to store when v is inserted at a key holding old.
+func (t *treeInfo) merge(old, v interface{}) interface{} {
+	t.admit(v)
+	switch {
+	case t == nil:
+		return v
+	case t.tie == KeepFirst:
+		return old
+	case t.tie == Append:
+		return append(old.([]interface{}), v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,55 @@
+package bst
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTiePolicy(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		policy TiePolicy
+		want   string
+	}{
+		{name: "Overwrite", policy: Overwrite, want: "second"},
+		{name: "KeepFirst", policy: KeepFirst, want: "first"},
+		{name: "Append", policy: Append, want: "[first second]"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s := NewBasicPolicy(c.policy)
+			s.Insert(iKey(1), "first")
+			s.Insert(iKey(2), "other")
+			s.Insert(iKey(1), "second")
+			v, _ := s.Lookup(iKey(1))
+			if got := fmt.Sprint(v); got != c.want {
+				t.Errorf("bad retained value: got %s, want %s", got, c.want)
+			}
+			if s.Len() != 2 {
+				t.Errorf("bad Len: got %d, want 2", s.Len())
+			}
+		})
+		t.Run(c.name+"Persistent", func(t *testing.T) {
+			s := NewBasicPolicy(c.policy)
+			s = s.PersistentInsert(iKey(1), "first")
+			s = s.PersistentInsert(iKey(2), "other")
+			p := s.PersistentInsert(iKey(1), "second")
+			v, _ := p.Lookup(iKey(1))
+			if got := fmt.Sprint(v); got != c.want {
+				t.Errorf("bad retained value: got %s, want %s", got, c.want)
+			}
+			q := p.PersistentInsert(iKey(1), "third")
+			r := p.PersistentInsert(iKey(1), "fourth")
+			if c.policy == Append {
+				if got := fmt.Sprint(q.Get(iKey(1)).Value); got != "[first second third]" {
+					t.Errorf("bad appended value: got %s", got)
+				}
+				if got := fmt.Sprint(r.Get(iKey(1)).Value); got != "[first second fourth]" {
+					t.Errorf("bad appended value in sibling version: got %s", got)
+				}
+			}
+			if got, want := fmt.Sprint(p.Get(iKey(1)).Value), c.want; got != want {
+				t.Errorf("earlier version changed: got %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package bst
+
+import "sync/atomic"
+
+// bump advances the tree's version and returns it. It is atomic so that the
+// readers of an AtomicBST can call Version while a writer inserts.
+func (t *treeInfo) bump() uint64 {
+	if t == nil {
+		return 0
+	}
+	return atomic.AddUint64(&t.ver, 1)
+}
+
+// nextID returns the next node ID for the tree.
+func (t *treeInfo) nextID() uint64 {
+	if t == nil {
+		return 0
+	}
+	t.ids++
+	return t.ids
+}
+
+// ID returns an identifier for the node, unique within its tree and assigned
+// in the order the tree made its nodes, so stable across runs making the same
+// inserts. The sentinel's is 0.
+func (n *BasicBST) ID() uint64 {
+	return n.id
+}
+
+// Version returns the tree's current version, which advances with every value
+// written by Insert, GetOrInsert, InsertGuarded, Update, MapValues or
+// PersistentInsert.
+// Trees made from one another by PersistentInsert share a version counter.
+func (n *BasicBST) Version() uint64 {
+	if n.info == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&n.info.ver)
+}
+
+// ChangedSince calls f, in tree order, on each node whose value was written
+// after the tree was at version v. Deleted keys are not reported.
+func (n *BasicBST) ChangedSince(v uint64, f func(*BasicBST)) {
+	n.Visit(func(n *BasicBST) error {
+		if n.ver > v {
+			f(n)
+		}
+		return nil
+	})
+}
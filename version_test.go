@@ -0,0 +1,82 @@
+package bst
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestChangedSince(t *testing.T) {
+	s := newSeq(20)
+	v := s.Version()
+	if v != 20 {
+		t.Errorf("bad version after 20 inserts: %d", v)
+	}
+	s.Insert(iKey(3), 3)
+	s.Update(iKey(17), func(old interface{}) interface{} { return 17 })
+	s.Insert(iKey(25), -25)
+	s.Update(iKey(99), func(old interface{}) interface{} { return 99 })
+	var got []int
+	s.ChangedSince(v, func(n *BasicBST) {
+		got = append(got, int(n.Key.(iKey)))
+	})
+	if want := []int{3, 17, 25}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("bad changed keys: got %v, want %v", got, want)
+	}
+	s.ChangedSince(s.Version(), func(n *BasicBST) {
+		t.Errorf("unexpected change at %v", n.Key)
+	})
+}
+
+func TestChangedSinceRebuilt(t *testing.T) {
+	changed := func(n *BasicBST, v uint64) []int {
+		var got []int
+		n.ChangedSince(v, func(n *BasicBST) {
+			got = append(got, int(n.Key.(iKey)))
+		})
+		return got
+	}
+	t.Run("rebalance", func(t *testing.T) {
+		s := newSeq(20)
+		v := s.Version()
+		s.Insert(iKey(3), -3)
+		s.Rebalance()
+		if got := changed(s, v); fmt.Sprint(got) != "[3]" {
+			t.Errorf("bad changed keys: got %v, want [3]", got)
+		}
+	})
+	t.Run("filter", func(t *testing.T) {
+		s := newSeq(20)
+		v := s.Version()
+		s.Insert(iKey(4), -4)
+		f := s.Filter(func(k KeyType, _ interface{}) bool { return k.(iKey)%2 == 0 })
+		if got := changed(f, v); fmt.Sprint(got) != "[4]" {
+			t.Errorf("bad changed keys: got %v, want [4]", got)
+		}
+		if f.Version() != s.Version() {
+			t.Errorf("bad filtered version: got %d, want %d", f.Version(), s.Version())
+		}
+	})
+	t.Run("mapvalues", func(t *testing.T) {
+		s := newSeq(20)
+		v := s.Version()
+		s.MapValues(func(k KeyType, old interface{}) interface{} { return old })
+		if got := changed(s, v); len(got) != 20 {
+			t.Errorf("bad changed count after MapValues: got %d, want 20", len(got))
+		}
+		if s.Version() != v+20 {
+			t.Errorf("bad version after MapValues: got %d, want %d", s.Version(), v+20)
+		}
+	})
+	t.Run("persistent", func(t *testing.T) {
+		s := newSeq(20)
+		v := s.Version()
+		p := s.PersistentInsert(iKey(5), -5)
+		p = p.PersistentInsert(iKey(30), 30)
+		if got := changed(p, v); fmt.Sprint(got) != "[5 30]" {
+			t.Errorf("bad changed keys: got %v, want [5 30]", got)
+		}
+		if got := changed(s, v); len(got) != 0 {
+			t.Errorf("bad changed keys in original: got %v, want none", got)
+		}
+	})
+}
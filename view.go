@@ -0,0 +1,40 @@
+package bst
+
+// NodeView is a read-only handle on a BasicBST node. Unlike the node itself
+// it exposes no links or fields, so holders cannot break the tree's
+// invariants. The zero NodeView refers to no node.
+type NodeView struct {
+	n *BasicBST
+}
+
+// GetView returns a view of the node with key k, or the zero NodeView if
+// there is none.
+func (n *BasicBST) GetView(k KeyType) NodeView {
+	return NodeView{n.Get(k)}
+}
+
+// Valid reports whether the view refers to a node.
+func (v NodeView) Valid() bool {
+	return v.n != nil
+}
+
+// Key returns the node's key.
+func (v NodeView) Key() KeyType {
+	return v.n.Key
+}
+
+// Value returns the node's value.
+func (v NodeView) Value() interface{} {
+	return v.n.Value
+}
+
+// Next returns a view of the next node, or the zero NodeView past the end.
+func (v NodeView) Next() NodeView {
+	return NodeView{v.n.Next()}
+}
+
+// Prev returns a view of the previous node, or the zero NodeView before the
+// start.
+func (v NodeView) Prev() NodeView {
+	return NodeView{v.n.Prev()}
+}
@@ -0,0 +1,47 @@
+package bst
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNodeView(t *testing.T) {
+	s := newSeq(100)
+	t.Run("navigation", func(t *testing.T) {
+		for k := 0; k < 100; k++ {
+			v, n := s.GetView(iKey(k)), s.Get(iKey(k))
+			if v.Key() != n.Key || v.Value() != n.Value {
+				t.Errorf("bad view of %d: got %v/%v", k, v.Key(), v.Value())
+			}
+			for _, c := range []struct {
+				name string
+				v    NodeView
+				n    *BasicBST
+			}{{"Next", v.Next(), n.Next()}, {"Prev", v.Prev(), n.Prev()}} {
+				switch {
+				case c.v.Valid() != (c.n != nil):
+					t.Errorf("bad %s validity from %d: got %v", c.name, k, c.v.Valid())
+				case c.n != nil && c.v.Key() != c.n.Key:
+					t.Errorf("bad %s from %d: got %v, want %v", c.name, k, c.v.Key(), c.n.Key)
+				}
+			}
+		}
+		if v := s.GetView(iKey(100)); v.Valid() {
+			t.Errorf("unexpected view of a missing key")
+		}
+	})
+	t.Run("read-only", func(t *testing.T) {
+		typ := reflect.TypeOf(NodeView{})
+		if typ.NumField() != 1 || typ.Field(0).IsExported() {
+			t.Errorf("NodeView exposes fields")
+		}
+		want := map[string]bool{"Key": true, "Value": true, "Next": true, "Prev": true, "Valid": true}
+		for _, typ := range []reflect.Type{typ, reflect.PointerTo(typ)} {
+			for i := 0; i < typ.NumMethod(); i++ {
+				if m := typ.Method(i).Name; !want[m] {
+					t.Errorf("unexpected method %s on %s", m, typ)
+				}
+			}
+		}
+	})
+}